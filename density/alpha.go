@@ -0,0 +1,106 @@
+package density
+
+import (
+	"image/color"
+)
+
+// AlphaPolicy tells how a Model treats the transparency of colours.
+type AlphaPolicy int
+
+const (
+	// Premultiplied models convert colours as returned by their
+	// RGBA method, with the channels premultiplied by alpha. This
+	// darkens transparent colours towards black, and is what all
+	// the predefined models do.
+	Premultiplied AlphaPolicy = iota
+	// Unpremultiplied models convert the colour of a pixel as if it
+	// were opaque, regardless of its transparency. Fully transparent
+	// colours are taken to be black.
+	Unpremultiplied
+	// Composited models convert colours composited over an opaque
+	// background colour.
+	Composited
+)
+
+// An AlphaModel is a Model that states its AlphaPolicy.
+type AlphaModel interface {
+	Model
+	AlphaPolicy() AlphaPolicy
+}
+
+// PolicyOf returns the AlphaPolicy of m: that of an AlphaModel, or
+// Premultiplied for any other Model.
+func PolicyOf(m Model) AlphaPolicy {
+	if am, ok := m.(AlphaModel); ok {
+		return am.AlphaPolicy()
+	}
+	return Premultiplied
+}
+
+// Unpremultiply returns a Model that un-premultiplies colours before
+// m converts them, so that a half-transparent white gives the same
+// density as an opaque one. As m sees opaque colours only, wrapping
+// AlphaDensity gives full density everywhere.
+func Unpremultiply(m Model) AlphaModel {
+	return &unpremultiplied{m}
+}
+
+type unpremultiplied struct {
+	m Model
+}
+
+func (u *unpremultiplied) AlphaPolicy() AlphaPolicy { return Unpremultiplied }
+
+func (u *unpremultiplied) Convert(c color.Color) (d uint16) {
+	r, g, b, a := c.RGBA()
+	if a == 0xFFFF {
+		return u.m.Convert(c)
+	}
+	r, g, b, a = unpremultiply(r, g, b, a)
+	return u.m.Convert(color.RGBA64{uint16(r), uint16(g), uint16(b), uint16(a)})
+}
+
+// unpremultiply gives the channels of the opaque colour that stands in
+// for the colour with premultiplied channels r, g, b and a.
+func unpremultiply(r, g, b, a uint32) (ur, ug, ub, ua uint32) {
+	switch a {
+	case 0xFFFF:
+		return r, g, b, a
+	case 0:
+		return 0, 0, 0, 0xFFFF
+	}
+	return (r*0xFFFF + a/2) / a, (g*0xFFFF + a/2) / a, (b*0xFFFF + a/2) / a, 0xFFFF
+}
+
+// Over returns a Model that composites colours over the background
+// colour bg before m converts them. Transparent pixels in an image
+// then get the density of the background, and half-transparent ones
+// a mix of both. A background that is not opaque is composited over
+// black first.
+func Over(m Model, bg color.Color) AlphaModel {
+	r, g, b, _ := bg.RGBA()
+	return &composited{m, r, g, b}
+}
+
+type composited struct {
+	m       Model
+	r, g, b uint32
+}
+
+func (o *composited) AlphaPolicy() AlphaPolicy { return Composited }
+
+func (o *composited) Convert(c color.Color) (d uint16) {
+	r, g, b, a := c.RGBA()
+	if a == 0xFFFF {
+		return o.m.Convert(c)
+	}
+	r, g, b, a = o.over(r, g, b, a)
+	return o.m.Convert(color.RGBA64{uint16(r), uint16(g), uint16(b), uint16(a)})
+}
+
+// over gives the channels of the colour with premultiplied channels
+// r, g, b and a, composited over the background.
+func (o *composited) over(r, g, b, a uint32) (cr, cg, cb, ca uint32) {
+	t := 0xFFFF - a
+	return r + (o.r*t+0x7FFF)/0xFFFF, g + (o.g*t+0x7FFF)/0xFFFF, b + (o.b*t+0x7FFF)/0xFFFF, 0xFFFF
+}
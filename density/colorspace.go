@@ -0,0 +1,181 @@
+package density
+
+import (
+	"math"
+)
+
+// Colour space models for density functions, that map a single
+// component of a colour in another colour space to a density.
+//
+// Hue is the angle on the colour wheel, from red through green and
+// blue back to red; greys have a hue of zero. The HSV and HSL models
+// give the saturation, value and lightness of those colour spaces.
+//
+// The CIELAB a* and b* models give the green-red and blue-yellow
+// components, with neutral colours at half density. The range of
+// both, from -128 to 128, covers all sRGB colours. Cb and Cr are the
+// chroma components of the full range YCbCr of JPEG, also with
+// neutral colours at half density.
+//
+// The CMYK models give the ink coverage of a naive separation, with
+// as much black as possible, as done by color.CMYKModel.
+//
+// The negative counterparts give the complement of each.
+var (
+	HueDensity              Model = rgbaFunc(hueDensity)
+	HSVSaturationDensity    Model = rgbaFunc(hsvSaturationDensity)
+	HSVValueDensity         Model = rgbaFunc(hsvValueDensity)
+	HSLSaturationDensity    Model = rgbaFunc(hslSaturationDensity)
+	HSLLightnessDensity     Model = rgbaFunc(hslLightnessDensity)
+	LabADensity             Model = rgbaFunc(labADensity)
+	LabBDensity             Model = rgbaFunc(labBDensity)
+	CbDensity               Model = rgbaFunc(cbDensity)
+	CrDensity               Model = rgbaFunc(crDensity)
+	CyanDensity             Model = rgbaFunc(cyanDensity)
+	MagentaDensity          Model = rgbaFunc(magentaDensity)
+	YellowDensity           Model = rgbaFunc(yellowDensity)
+	KeyDensity              Model = rgbaFunc(keyDensity)
+	NegHueDensity           Model = rgbaFunc(negate(hueDensity))
+	NegHSVSaturationDensity Model = rgbaFunc(negate(hsvSaturationDensity))
+	NegHSVValueDensity      Model = rgbaFunc(negate(hsvValueDensity))
+	NegHSLSaturationDensity Model = rgbaFunc(negate(hslSaturationDensity))
+	NegHSLLightnessDensity  Model = rgbaFunc(negate(hslLightnessDensity))
+	NegLabADensity          Model = rgbaFunc(negate(labADensity))
+	NegLabBDensity          Model = rgbaFunc(negate(labBDensity))
+	NegCbDensity            Model = rgbaFunc(negate(cbDensity))
+	NegCrDensity            Model = rgbaFunc(negate(crDensity))
+	NegCyanDensity          Model = rgbaFunc(negate(cyanDensity))
+	NegMagentaDensity       Model = rgbaFunc(negate(magentaDensity))
+	NegYellowDensity        Model = rgbaFunc(negate(yellowDensity))
+	NegKeyDensity           Model = rgbaFunc(negate(keyDensity))
+)
+
+// maxMin gives the largest and smallest of the channels r, g and b.
+func maxMin(r, g, b uint32) (max, min uint32) {
+	max, min = r, r
+	for _, v := range [2]uint32{g, b} {
+		if v > max {
+			max = v
+		}
+		if v < min {
+			min = v
+		}
+	}
+	return
+}
+
+func hueDensity(r, g, b, _ uint32) (d uint16) {
+	max, min := maxMin(r, g, b)
+	if max == min {
+		return 0
+	}
+	fr, fg, fb, delta := float64(r), float64(g), float64(b), float64(max-min)
+	var h float64
+	switch max {
+	case r:
+		h = (fg - fb) / delta
+		if h < 0 {
+			h += 6
+		}
+	case g:
+		h = (fb-fr)/delta + 2
+	default:
+		h = (fr-fg)/delta + 4
+	}
+	return unit(h / 6)
+}
+
+func hsvSaturationDensity(r, g, b, _ uint32) (d uint16) {
+	max, min := maxMin(r, g, b)
+	if max == 0 {
+		return 0
+	}
+	return uint16(((max-min)*0xFFFF + max/2) / max)
+}
+
+func hsvValueDensity(r, g, b, _ uint32) (d uint16) {
+	max, _ := maxMin(r, g, b)
+	return uint16(max)
+}
+
+func hslSaturationDensity(r, g, b, _ uint32) (d uint16) {
+	max, min := maxMin(r, g, b)
+	// The chroma over 1 - |2L - 1|, with L halfway max and min.
+	den := max + min
+	if den > 0xFFFF {
+		den = 2*0xFFFF - den
+	}
+	if den == 0 {
+		return 0
+	}
+	return uint16((uint64(max-min)*0xFFFF + uint64(den)/2) / uint64(den))
+}
+
+func hslLightnessDensity(r, g, b, _ uint32) (d uint16) {
+	max, min := maxMin(r, g, b)
+	return uint16((max + min + 1) / 2)
+}
+
+// lab gives the CIELAB a* and b* of the colour with channels r, g
+// and bl, for a D65 white point.
+func lab(r, g, bl uint32) (a, b float64) {
+	lr, lg, lb := toLinear(r), toLinear(g), toLinear(bl)
+	x := (0.4124564*lr + 0.3575761*lg + 0.1804375*lb) / 0.95047
+	y := 0.2126729*lr + 0.7151522*lg + 0.0721750*lb
+	z := (0.0193339*lr + 0.1191920*lg + 0.9503041*lb) / 1.08883
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return 500 * (fx - fy), 200 * (fy - fz)
+}
+
+func labADensity(r, g, b, _ uint32) (d uint16) {
+	la, _ := lab(r, g, b)
+	return unit((la + 128) / 256)
+}
+
+func labBDensity(r, g, b, _ uint32) (d uint16) {
+	_, lb := lab(r, g, b)
+	return unit((lb + 128) / 256)
+}
+
+func cbDensity(r, g, b, _ uint32) (d uint16) {
+	return unit(0.5 + (-0.168736*float64(r)-0.331264*float64(g)+0.5*float64(b))/0xFFFF)
+}
+
+func crDensity(r, g, b, _ uint32) (d uint16) {
+	return unit(0.5 + (0.5*float64(r)-0.418688*float64(g)-0.081312*float64(b))/0xFFFF)
+}
+
+// ink gives the coverage of the ink that absorbs channel v, after
+// max, the largest channel, has been taken care of by black ink.
+func ink(v, max uint32) uint16 {
+	if max == 0 {
+		return 0
+	}
+	return uint16(((max-v)*0xFFFF + max/2) / max)
+}
+
+func cyanDensity(r, g, b, _ uint32) (d uint16) {
+	max, _ := maxMin(r, g, b)
+	return ink(r, max)
+}
+
+func magentaDensity(r, g, b, _ uint32) (d uint16) {
+	max, _ := maxMin(r, g, b)
+	return ink(g, max)
+}
+
+func yellowDensity(r, g, b, _ uint32) (d uint16) {
+	max, _ := maxMin(r, g, b)
+	return ink(b, max)
+}
+
+func keyDensity(r, g, b, _ uint32) (d uint16) {
+	max, _ := maxMin(r, g, b)
+	return uint16(0xFFFF - max)
+}
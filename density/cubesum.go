@@ -68,12 +68,11 @@ func (cbs *CubeSum) ValueAt(x, y, z int) (v uint64) {
 	return
 }
 
-func (cbs *CubeSum) NegValueAt(x, y, z int) (v uint64) {
-	if (image.Point{x, y}.In(cbs.Rect)) && z >= 0 && z < cbs.LenZ {
-		i := cbs.DVOffSet(x, y, z)
-		v = uint64(x+1-cbs.Rect.Min.X)*uint64(y+1-cbs.Rect.Min.Y)*uint64(z+1)*0xFFFF - cbs.Values[i]
-	}
-	return
+// NegValueAt is ValueAt for the negative densities.
+//
+// Deprecated: use the ValueAt of a CubeComplement of cbs.
+func (cbs *CubeSum) NegValueAt(x, y, z int) uint64 {
+	return CubeComplement{cbs}.ValueAt(x, y, z)
 }
 
 // Sums the volume defined by the rectangle and zmin-zmax. Inclusive min, exclusive max (like image.Rectangle)
@@ -93,388 +92,52 @@ func (cbs *CubeSum) VolumeSum(r image.Rectangle, zmin, zmax int) uint64 {
 }
 
 // Like Sum, but gives the value of (volume*0xFFFF - Sum) - the negative space, essentially
+//
+// Deprecated: use the VolumeSum of a CubeComplement of cbs.
 func (cbs *CubeSum) NegVolumeSum(r image.Rectangle, zmin, zmax int) uint64 {
-	r = r.Intersect(cbs.Rect).Sub(image.Point{1, 1})
-	if zmax >= cbs.LenZ {
-		zmax = cbs.LenZ - 1
-	} else {
-		zmax -= 1
-	}
-	zmin--
-	volumeMass := uint64(r.Dx()) * uint64(r.Dy()) * uint64(zmax-zmin) * 0xFFFF
-	return volumeMass -
-		cbs.ValueAt(r.Max.X, r.Max.Y, zmax) - cbs.ValueAt(r.Min.X, r.Min.Y, zmax) +
-		cbs.ValueAt(r.Min.X, r.Max.Y, zmax) + cbs.ValueAt(r.Max.X, r.Min.Y, zmax) +
-		cbs.ValueAt(r.Max.X, r.Max.Y, zmin) + cbs.ValueAt(r.Min.X, r.Min.Y, zmin) -
-		cbs.ValueAt(r.Min.X, r.Max.Y, zmin) - cbs.ValueAt(r.Max.X, r.Min.Y, zmin)
+	return CubeComplement{cbs}.VolumeSum(r, zmin, zmax)
 }
 
 // Given a Rectangle and zmin/zmax, finds x closest to line dividing
 // the mass of the cube bound by these coordinates in half.
 func (cbs *CubeSum) FindCx(r image.Rectangle, zmin, zmax int) int {
-	xmin := r.Min.X
-	xmax := r.Max.X
-	r = r.Sub(image.Point{1, 1})
-	zmin--
-	zmax--
-	x := (xmax + xmin + 1) / 2
-	xmaxymaxzmax := cbs.ValueAt(r.Max.X, r.Max.Y, zmax)
-	xminyminzmax := cbs.ValueAt(r.Min.X, r.Min.Y, zmax)
-	xminymaxzmax := cbs.ValueAt(r.Min.X, r.Max.Y, zmax)
-	xmaxyminzmax := cbs.ValueAt(r.Max.X, r.Min.Y, zmax)
-	xmaxymaxzmin := cbs.ValueAt(r.Max.X, r.Max.Y, zmin)
-	xminyminzmin := cbs.ValueAt(r.Min.X, r.Min.Y, zmin)
-	xminymaxzmin := cbs.ValueAt(r.Min.X, r.Max.Y, zmin)
-	xmaxyminzmin := cbs.ValueAt(r.Max.X, r.Min.Y, zmin)
-	for {
-		// The centre of mass is probably not a round number,
-		// so we aim to iterate only to the margin of 1 pixel
-		if xmax-xmin > 1 {
-			cxyminzmax := cbs.ValueAt(x, r.Min.Y, zmax)
-			cxymaxzmax := cbs.ValueAt(x, r.Max.Y, zmax)
-			cxyminzmin := cbs.ValueAt(x, r.Min.Y, zmin)
-			cxymaxzmin := cbs.ValueAt(x, r.Max.Y, zmin)
-			lmass := (cxymaxzmax - cxyminzmax - xminymaxzmax + xminyminzmax) -
-				(cxymaxzmin - cxyminzmin - xminymaxzmin + xminyminzmin)
-			rmass := (xmaxymaxzmax - cxymaxzmax - xmaxyminzmax + cxyminzmax) -
-				(xmaxymaxzmin - cxymaxzmin - xmaxyminzmin + cxyminzmin)
-			if lmass < rmass {
-				xmin = x
-				x = (x + xmax + 1) / 2
-			} else {
-				xmax = x
-				x = (x + xmin + 1) / 2
-			}
-		} else {
-			// Round down to whichever side differs the least from total mass
-			// Note that lmass and rmass are guaranteed to be smaller than total mass
-			cxyminzmax := cbs.ValueAt(xmin, r.Min.Y, zmax)
-			cxymaxzmax := cbs.ValueAt(xmin, r.Max.Y, zmax)
-			cxyminzmin := cbs.ValueAt(xmin, r.Min.Y, zmin)
-			cxymaxzmin := cbs.ValueAt(xmin, r.Max.Y, zmin)
-			lmass := (cxymaxzmax - cxyminzmax - xminymaxzmax + xminyminzmax) -
-				(cxymaxzmin - cxyminzmin - xminymaxzmin + xminyminzmin)
-			cxyminzmax = cbs.ValueAt(xmax, r.Min.Y, zmax)
-			cxymaxzmax = cbs.ValueAt(xmax, r.Max.Y, zmax)
-			cxyminzmin = cbs.ValueAt(xmax, r.Min.Y, zmin)
-			cxymaxzmin = cbs.ValueAt(xmax, r.Max.Y, zmin)
-			rmass := (xmaxymaxzmax - cxymaxzmax - xmaxyminzmax + cxyminzmax) -
-				(xmaxymaxzmin - cxymaxzmin - xmaxyminzmin + cxyminzmin)
-				//			tmass := (xmaxymaxzmax - xmaxyminzmax - xminymaxzmax + xminyminzmax) -
-				//				(xmaxymaxzmin - xmaxyminzmin - xminymaxzmin + xminyminzmin)
-			if lmass > rmass {
-				x = xmin
-			} else {
-				x = xmax
-			}
-			break
-		}
-	}
-	return x
+	return cbs.FindQx(r, zmin, zmax, 0.5)
 }
 
 // Given a Rectangle and zmin/zmax, finds y closest to line dividing
 // the mass of the cube bound by these coordinates in half.
 func (cbs *CubeSum) FindCy(r image.Rectangle, zmin, zmax int) int {
-	ymin := r.Min.Y
-	ymax := r.Max.Y
-	r = r.Sub(image.Point{1, 1})
-	zmax--
-	zmin--
-	y := (ymax + ymin + 1) / 2
-	xmaxymaxzmax := cbs.ValueAt(r.Max.X, r.Max.Y, zmax)
-	xminyminzmax := cbs.ValueAt(r.Min.X, r.Min.Y, zmax)
-	xminymaxzmax := cbs.ValueAt(r.Min.X, r.Max.Y, zmax)
-	xmaxyminzmax := cbs.ValueAt(r.Max.X, r.Min.Y, zmax)
-	xmaxymaxzmin := cbs.ValueAt(r.Max.X, r.Max.Y, zmin)
-	xminyminzmin := cbs.ValueAt(r.Min.X, r.Min.Y, zmin)
-	xminymaxzmin := cbs.ValueAt(r.Min.X, r.Max.Y, zmin)
-	xmaxyminzmin := cbs.ValueAt(r.Max.X, r.Min.Y, zmin)
-	for {
-		if ymax-ymin > 1 {
-			xmincyzmax := cbs.ValueAt(r.Min.X, y, zmax)
-			xmaxcyzmax := cbs.ValueAt(r.Max.X, y, zmax)
-			xmincyzmin := cbs.ValueAt(r.Min.X, y, zmin)
-			xmaxcyzmin := cbs.ValueAt(r.Max.X, y, zmin)
-			upmass := (xmaxcyzmax - xmincyzmax - xmaxyminzmax + xminyminzmax) -
-				(xmaxcyzmin - xmincyzmin - xmaxyminzmin + xminyminzmin)
-			downmass := (xmaxymaxzmax - xmaxcyzmax - xminymaxzmax + xmincyzmax) -
-				(xmaxymaxzmin - xmaxcyzmin - xminymaxzmin + xmincyzmin)
-			if upmass < downmass {
-				ymin = y
-				y = (y + ymax + 1) / 2
-			} else {
-				ymax = y
-				y = (y + ymin + 1) / 2
-			}
-		} else {
-			xmincyzmax := cbs.ValueAt(r.Min.X, ymin, zmax)
-			xmaxcyzmax := cbs.ValueAt(r.Max.X, ymin, zmax)
-			xmincyzmin := cbs.ValueAt(r.Min.X, ymin, zmin)
-			xmaxcyzmin := cbs.ValueAt(r.Max.X, ymin, zmin)
-			upmass := (xmaxcyzmax - xmincyzmax - xmaxyminzmax + xminyminzmax) -
-				(xmaxcyzmin - xmincyzmin - xmaxyminzmin + xminyminzmin)
-			xmincyzmax = cbs.ValueAt(r.Min.X, ymax, zmax)
-			xmaxcyzmax = cbs.ValueAt(r.Max.X, ymax, zmax)
-			xmincyzmin = cbs.ValueAt(r.Min.X, ymax, zmin)
-			xmaxcyzmin = cbs.ValueAt(r.Max.X, ymax, zmin)
-			downmass := (xmaxymaxzmax - xmaxcyzmax - xminymaxzmax + xmincyzmax) -
-				(xmaxymaxzmin - xmaxcyzmin - xminymaxzmin + xmincyzmin)
-				//			tmass := (xmaxymaxzmax - xmaxyminzmax - xminymaxzmax + xminyminzmax) -
-				//				(xmaxymaxzmin - xmaxyminzmin - xminymaxzmin + xminyminzmin)
-			if upmass > downmass {
-				y = ymin
-			} else {
-				y = ymax
-			}
-			break
-		}
-	}
-	return y
+	return cbs.FindQy(r, zmin, zmax, 0.5)
 }
 
-// Given a Rectangle and zmin/zmax, finds y closest to line dividing
+// Given a Rectangle and zmin/zmax, finds z closest to line dividing
 // the mass of the cube bound by these coordinates in half.
-func (cbs *CubeSum) FindCz(r image.Rectangle, MinZ, MaxZ int) int {
-	zmin := MinZ
-	zmax := MaxZ
-	r = r.Sub(image.Point{1, 1})
-	MinZ--
-	MaxZ--
-	z := (zmax + zmin + 1) / 2
-	xmaxymaxzmax := cbs.ValueAt(r.Max.X, r.Max.Y, MaxZ)
-	xminyminzmax := cbs.ValueAt(r.Min.X, r.Min.Y, MaxZ)
-	xminymaxzmax := cbs.ValueAt(r.Min.X, r.Max.Y, MaxZ)
-	xmaxyminzmax := cbs.ValueAt(r.Max.X, r.Min.Y, MaxZ)
-	xmaxymaxzmin := cbs.ValueAt(r.Max.X, r.Max.Y, MinZ)
-	xminyminzmin := cbs.ValueAt(r.Min.X, r.Min.Y, MinZ)
-	xminymaxzmin := cbs.ValueAt(r.Min.X, r.Max.Y, MinZ)
-	xmaxyminzmin := cbs.ValueAt(r.Max.X, r.Min.Y, MinZ)
-	for {
-		if zmax-zmin > 1 {
-			xmaxymaxcz := cbs.ValueAt(r.Max.X, r.Max.Y, z)
-			xminymaxcz := cbs.ValueAt(r.Min.X, r.Max.Y, z)
-			xmaxymincz := cbs.ValueAt(r.Max.X, r.Min.Y, z)
-			xminymincz := cbs.ValueAt(r.Min.X, r.Min.Y, z)
-			frontmass := (xmaxymaxcz - xminymaxcz - xmaxymincz + xminymincz) -
-				(xmaxymaxzmin - xmaxyminzmin - xminymaxzmin + xminyminzmin)
-			backmass := (xmaxymaxzmax - xmaxyminzmax - xminymaxzmax + xminyminzmax) -
-				(xmaxymaxcz - xminymaxcz - xmaxymincz + xminymincz)
-			if frontmass < backmass {
-				zmin = z
-				z = (z + zmax + 1) / 2
-			} else {
-				zmax = z
-				z = (z + zmin + 1) / 2
-			}
-		} else {
-			xmaxymaxcz := cbs.ValueAt(r.Max.X, r.Max.Y, zmin)
-			xminymaxcz := cbs.ValueAt(r.Min.X, r.Max.Y, zmin)
-			xmaxymincz := cbs.ValueAt(r.Max.X, r.Min.Y, zmin)
-			xminymincz := cbs.ValueAt(r.Min.X, r.Min.Y, zmin)
-			frontmass := (xmaxymaxcz - xminymaxcz - xmaxymincz + xminymincz) -
-				(xmaxymaxzmin - xmaxyminzmin - xminyminzmin + xminyminzmin)
-			xmaxymaxcz = cbs.ValueAt(r.Max.X, r.Max.Y, zmax)
-			xminymaxcz = cbs.ValueAt(r.Min.X, r.Max.Y, zmax)
-			xmaxymincz = cbs.ValueAt(r.Max.X, r.Min.Y, zmax)
-			xminymincz = cbs.ValueAt(r.Min.X, r.Min.Y, zmax)
-			backmass := (xmaxymaxzmax - xmaxyminzmax - xminymaxzmax + xminyminzmax) -
-				(xmaxymaxcz - xminymaxcz - xmaxymincz + xminymincz)
-				//			tmass := (xmaxymaxzmax - xmaxyminzmax - xminymaxzmax + xminyminzmax) -
-				//				(xmaxymaxzmin - xmaxyminzmin - xminymaxzmin + xminyminzmin)
-			if frontmass > backmass {
-				z = zmin
-			} else {
-				z = zmax
-			}
-			break
-		}
-	}
-	return z
+func (cbs *CubeSum) FindCz(r image.Rectangle, zmin, zmax int) int {
+	return cbs.FindQz(r, zmin, zmax, 0.5)
 }
 
 // Given a Rectangle and zmin/zmax, finds x closest to line dividing
 // the "negative" mass of the cube bound by these coordinates mass in half.
+//
+// Deprecated: use the FindCx of a CubeComplement of cbs.
 func (cbs *CubeSum) FindNegCx(r image.Rectangle, zmin, zmax int) int {
-	xmin := r.Min.X
-	xmax := r.Max.X
-	r = r.Sub(image.Point{1, 1})
-	zmin--
-	zmax--
-	x := (xmax + xmin + 1) / 2
-	xmaxymaxzmax := cbs.NegValueAt(r.Max.X, r.Max.Y, zmax)
-	xminyminzmax := cbs.NegValueAt(r.Min.X, r.Min.Y, zmax)
-	xminymaxzmax := cbs.NegValueAt(r.Min.X, r.Max.Y, zmax)
-	xmaxyminzmax := cbs.NegValueAt(r.Max.X, r.Min.Y, zmax)
-	xmaxymaxzmin := cbs.NegValueAt(r.Max.X, r.Max.Y, zmin)
-	xminyminzmin := cbs.NegValueAt(r.Min.X, r.Min.Y, zmin)
-	xminymaxzmin := cbs.NegValueAt(r.Min.X, r.Max.Y, zmin)
-	xmaxyminzmin := cbs.NegValueAt(r.Max.X, r.Min.Y, zmin)
-	for {
-		// The centre of mass is probably not a round number,
-		// so we aim to iterate only to the margin of 1 pixel
-		if xmax-xmin > 1 {
-			cxyminzmax := cbs.NegValueAt(x, r.Min.Y, zmax)
-			cxymaxzmax := cbs.NegValueAt(x, r.Max.Y, zmax)
-			cxyminzmin := cbs.NegValueAt(x, r.Min.Y, zmin)
-			cxymaxzmin := cbs.NegValueAt(x, r.Max.Y, zmin)
-			lmass := (cxymaxzmax - cxyminzmax - xminymaxzmax + xminyminzmax) -
-				(cxymaxzmin - cxyminzmin - xminymaxzmin + xminyminzmin)
-			rmass := (xmaxymaxzmax - cxymaxzmax - xmaxyminzmax + cxyminzmax) -
-				(xmaxymaxzmin - cxymaxzmin - xmaxyminzmin + cxyminzmin)
-			if lmass < rmass {
-				xmin = x
-				x = (x + xmax + 1) / 2
-			} else {
-				xmax = x
-				x = (x + xmin + 1) / 2
-			}
-		} else {
-			// Round down to whichever side differs the least from total mass
-			// Note that lmass and rmass are guaranteed to be smaller than total mass
-			cxyminzmax := cbs.NegValueAt(xmin, r.Min.Y, zmax)
-			cxymaxzmax := cbs.NegValueAt(xmin, r.Max.Y, zmax)
-			cxyminzmin := cbs.NegValueAt(xmin, r.Min.Y, zmin)
-			cxymaxzmin := cbs.NegValueAt(xmin, r.Max.Y, zmin)
-			lmass := (cxymaxzmax - cxyminzmax - xminymaxzmax + xminyminzmax) -
-				(cxymaxzmin - cxyminzmin - xminymaxzmin + xminyminzmin)
-			cxyminzmax = cbs.NegValueAt(xmax, r.Min.Y, zmax)
-			cxymaxzmax = cbs.NegValueAt(xmax, r.Max.Y, zmax)
-			cxyminzmin = cbs.NegValueAt(xmax, r.Min.Y, zmin)
-			cxymaxzmin = cbs.NegValueAt(xmax, r.Max.Y, zmin)
-			rmass := (xmaxymaxzmax - cxymaxzmax - xmaxyminzmax + cxyminzmax) -
-				(xmaxymaxzmin - cxymaxzmin - xmaxyminzmin + cxyminzmin)
-				//			tmass := (xmaxymaxzmax - xmaxyminzmax - xminymaxzmax + xminyminzmax) -
-				//				(xmaxymaxzmin - xmaxyminzmin - xminymaxzmin + xminyminzmin)
-			if lmass > rmass {
-				x = xmin
-			} else {
-				x = xmax
-			}
-			break
-		}
-	}
-	return x
+	return CubeComplement{cbs}.FindCx(r, zmin, zmax)
 }
 
 // Given a Rectangle and zmin/zmax, finds y closest to line dividing
 // the "negative" mass of the cube bound by these coordinates mass in half.
+//
+// Deprecated: use the FindCy of a CubeComplement of cbs.
 func (cbs *CubeSum) FindNegCy(r image.Rectangle, zmin, zmax int) int {
-	ymin := r.Min.Y
-	ymax := r.Max.Y
-	r = r.Sub(image.Point{1, 1})
-	zmax--
-	zmin--
-	y := (ymax + ymin + 1) / 2
-	xmaxymaxzmax := cbs.NegValueAt(r.Max.X, r.Max.Y, zmax)
-	xminyminzmax := cbs.NegValueAt(r.Min.X, r.Min.Y, zmax)
-	xminymaxzmax := cbs.NegValueAt(r.Min.X, r.Max.Y, zmax)
-	xmaxyminzmax := cbs.NegValueAt(r.Max.X, r.Min.Y, zmax)
-	xmaxymaxzmin := cbs.NegValueAt(r.Max.X, r.Max.Y, zmin)
-	xminyminzmin := cbs.NegValueAt(r.Min.X, r.Min.Y, zmin)
-	xminymaxzmin := cbs.NegValueAt(r.Min.X, r.Max.Y, zmin)
-	xmaxyminzmin := cbs.NegValueAt(r.Max.X, r.Min.Y, zmin)
-	for {
-		if ymax-ymin > 1 {
-			xmincyzmax := cbs.NegValueAt(r.Min.X, y, zmax)
-			xmaxcyzmax := cbs.NegValueAt(r.Max.X, y, zmax)
-			xmincyzmin := cbs.NegValueAt(r.Min.X, y, zmin)
-			xmaxcyzmin := cbs.NegValueAt(r.Max.X, y, zmin)
-			upmass := (xmaxcyzmax - xmincyzmax - xmaxyminzmax + xminyminzmax) -
-				(xmaxcyzmin - xmincyzmin - xmaxyminzmin + xminyminzmin)
-			downmass := (xmaxymaxzmax - xmaxcyzmax - xminymaxzmax + xmincyzmax) -
-				(xmaxymaxzmin - xmaxcyzmin - xminymaxzmin + xmincyzmin)
-			if upmass < downmass {
-				ymin = y
-				y = (y + ymax + 1) / 2
-			} else {
-				ymax = y
-				y = (y + ymin + 1) / 2
-			}
-		} else {
-			xmincyzmax := cbs.NegValueAt(r.Min.X, ymin, zmax)
-			xmaxcyzmax := cbs.NegValueAt(r.Max.X, ymin, zmax)
-			xmincyzmin := cbs.NegValueAt(r.Min.X, ymin, zmin)
-			xmaxcyzmin := cbs.NegValueAt(r.Max.X, ymin, zmin)
-			upmass := (xmaxcyzmax - xmincyzmax - xmaxyminzmax + xminyminzmax) -
-				(xmaxcyzmin - xmincyzmin - xmaxyminzmin + xminyminzmin)
-			xmincyzmax = cbs.NegValueAt(r.Min.X, ymax, zmax)
-			xmaxcyzmax = cbs.NegValueAt(r.Max.X, ymax, zmax)
-			xmincyzmin = cbs.NegValueAt(r.Min.X, ymax, zmin)
-			xmaxcyzmin = cbs.NegValueAt(r.Max.X, ymax, zmin)
-			downmass := (xmaxymaxzmax - xmaxcyzmax - xminymaxzmax + xmincyzmax) -
-				(xmaxymaxzmin - xmaxcyzmin - xminymaxzmin + xmincyzmin)
-				//			tmass := (xmaxymaxzmax - xmaxyminzmax - xminymaxzmax + xminyminzmax) -
-				//				(xmaxymaxzmin - xmaxyminzmin - xminymaxzmin + xminyminzmin)
-			if upmass > downmass {
-				y = ymin
-			} else {
-				y = ymax
-			}
-			break
-		}
-	}
-	return y
+	return CubeComplement{cbs}.FindCy(r, zmin, zmax)
 }
 
-// Given a Rectangle and zmin/zmax, finds y closest to line dividing
+// Given a Rectangle and zmin/zmax, finds z closest to line dividing
 // the "negative" mass of the cube bound by these coordinates mass in half.
-func (cbs *CubeSum) FindNegCz(r image.Rectangle, MinZ, MaxZ int) int {
-	zmin := MinZ
-	zmax := MaxZ
-	r = r.Sub(image.Point{1, 1})
-	MinZ--
-	MaxZ--
-	z := (zmax + zmin + 1) / 2
-	xmaxymaxzmax := cbs.NegValueAt(r.Max.X, r.Max.Y, MaxZ)
-	xminyminzmax := cbs.NegValueAt(r.Min.X, r.Min.Y, MaxZ)
-	xminymaxzmax := cbs.NegValueAt(r.Min.X, r.Max.Y, MaxZ)
-	xmaxyminzmax := cbs.NegValueAt(r.Max.X, r.Min.Y, MaxZ)
-	xmaxymaxzmin := cbs.NegValueAt(r.Max.X, r.Max.Y, MinZ)
-	xminyminzmin := cbs.NegValueAt(r.Min.X, r.Min.Y, MinZ)
-	xminymaxzmin := cbs.NegValueAt(r.Min.X, r.Max.Y, MinZ)
-	xmaxyminzmin := cbs.NegValueAt(r.Max.X, r.Min.Y, MinZ)
-	for {
-		if zmax-zmin > 1 {
-			xmaxymaxcz := cbs.NegValueAt(r.Max.X, r.Max.Y, z)
-			xminymaxcz := cbs.NegValueAt(r.Min.X, r.Max.Y, z)
-			xmaxymincz := cbs.NegValueAt(r.Max.X, r.Min.Y, z)
-			xminymincz := cbs.NegValueAt(r.Min.X, r.Min.Y, z)
-			frontmass := (xmaxymaxcz - xminymaxcz - xmaxymincz + xminymincz) -
-				(xmaxymaxzmin - xmaxyminzmin - xminymaxzmin + xminyminzmin)
-			backmass := (xmaxymaxzmax - xmaxyminzmax - xminymaxzmax + xminyminzmax) -
-				(xmaxymaxcz - xminymaxcz - xmaxymincz + xminymincz)
-			if frontmass < backmass {
-				zmin = z
-				z = (z + zmax + 1) / 2
-			} else {
-				zmax = z
-				z = (z + zmin + 1) / 2
-			}
-		} else {
-			xmaxymaxcz := cbs.NegValueAt(r.Max.X, r.Max.Y, zmin)
-			xminymaxcz := cbs.NegValueAt(r.Min.X, r.Max.Y, zmin)
-			xmaxymincz := cbs.NegValueAt(r.Max.X, r.Min.Y, zmin)
-			xminymincz := cbs.NegValueAt(r.Min.X, r.Min.Y, zmin)
-			frontmass := (xmaxymaxcz - xminymaxcz - xmaxymincz + xminymincz) -
-				(xmaxymaxzmin - xmaxyminzmin - xminyminzmin + xminyminzmin)
-			xmaxymaxcz = cbs.NegValueAt(r.Max.X, r.Max.Y, zmax)
-			xminymaxcz = cbs.NegValueAt(r.Min.X, r.Max.Y, zmax)
-			xmaxymincz = cbs.NegValueAt(r.Max.X, r.Min.Y, zmax)
-			xminymincz = cbs.NegValueAt(r.Min.X, r.Min.Y, zmax)
-			backmass := (xmaxymaxzmax - xmaxyminzmax - xminymaxzmax + xminyminzmax) -
-				(xmaxymaxcz - xminymaxcz - xmaxymincz + xminymincz)
-				//			tmass := (xmaxymaxzmax - xmaxyminzmax - xminymaxzmax + xminyminzmax) -
-				//				(xmaxymaxzmin - xmaxyminzmin - xminymaxzmin + xminyminzmin)
-			if frontmass > backmass {
-				z = zmin
-			} else {
-				z = zmax
-			}
-			break
-		}
-	}
-	return z
+//
+// Deprecated: use the FindCz of a CubeComplement of cbs.
+func (cbs *CubeSum) FindNegCz(r image.Rectangle, zmin, zmax int) int {
+	return CubeComplement{cbs}.FindCz(r, zmin, zmax)
 }
 
 func NewCubeSum(r image.Rectangle, capz int) *CubeSum {
@@ -483,55 +146,122 @@ func NewCubeSum(r image.Rectangle, capz int) *CubeSum {
 	return &CubeSum{Values: dv, Stride: w, Rect: r, LenZ: 0, CapZ: capz}
 }
 
-func CubeSumFrom(i *image.Image, d Model, capz int) *CubeSum {
-	r := (*i).Bounds()
+func CubeSumFrom(i image.Image, d Model, capz int) *CubeSum {
+	return CubeSumFromN(i, d, capz, 1)
+}
+
+// CubeSumFromN is like CubeSumFrom, but sums the first frame on n
+// goroutines.
+func CubeSumFromN(i image.Image, d Model, capz, n int) *CubeSum {
+	r := i.Bounds()
 	w, h := r.Dx(), r.Dy()
 	dv := make([]uint64, w*h*capz)
-
-	for x, vx := 0, uint64(0); x < w; x++ {
-		vx += uint64(d.Convert((*i).At(x+r.Min.X, r.Min.Y)))
-		dv[x] = vx
-	}
-
-	for y := 1; y < h; y++ {
-		for x, vx := 0, uint64(0); x < w; x++ {
-			vx += uint64(d.Convert(((*i).At(x+r.Min.X, y+r.Min.Y))))
-			dv[x+y*w] = vx + dv[x+(y-1)*w]
-		}
-	}
-
+	sumArea(dv, r, densities(i, d), n)
 	return &CubeSum{Values: dv, Stride: w, Rect: r, LenZ: 1, CapZ: capz}
 }
 
-func (cbs *CubeSum) AddFrame(i *image.Image, d Model) {
+func (cbs *CubeSum) AddFrame(i image.Image, d Model) {
+	cbs.AddFrameN(i, d, 1)
+}
+
+// AddFrameN is like AddFrame, but sums the frame on n goroutines.
+func (cbs *CubeSum) AddFrameN(i image.Image, d Model, n int) {
 	// Only add the part that overlaps
-	r := (*i).Bounds().Intersect(cbs.Rect)
+	r := i.Bounds().Intersect(cbs.Rect)
 	if !r.Empty() && cbs.LenZ < cbs.CapZ {
 		w := r.Dx()
 		h := r.Dy()
 		StrideZ := w * h
 
-		// Top row: only sum previous x
-		for x, vx := 0, uint64(0); x < w; x++ {
-			vx += uint64(d.Convert((*i).At(x+r.Min.X, r.Min.Y)))
-			cbs.Values[x+cbs.LenZ*StrideZ] = vx
-		}
-
-		// Rest: sum previous x, then add previous y.
-		for y := 1; y < h; y++ {
-			for x, vx := 0, uint64(0); x < w; x++ {
-				vx += uint64(d.Convert(((*i).At(x+r.Min.X, y+r.Min.Y))))
-				cbs.Values[x+y*w+cbs.LenZ*StrideZ] = vx + cbs.Values[x+(y-1)*w+cbs.LenZ*StrideZ]
-			}
-		}
+		// Sum previous x and y, then add previous z.
+		frame := cbs.Values[cbs.LenZ*StrideZ : (cbs.LenZ+1)*StrideZ]
+		sumArea(frame, r, densities(i, d), n)
 		if cbs.LenZ > 0 {
-			// Now add previous z
-			for y := 0; y < h; y++ {
-				for x := 0; x < w; x++ {
-					cbs.Values[x+y*w+cbs.LenZ*StrideZ] += cbs.Values[x+y*w+(cbs.LenZ-1)*StrideZ]
+			prev := cbs.Values[(cbs.LenZ-1)*StrideZ : cbs.LenZ*StrideZ]
+			parallel(h, n, func(lo, hi int) {
+				for k := lo * w; k < hi*w; k++ {
+					frame[k] += prev[k]
 				}
-			}
+			})
 		}
 		cbs.LenZ++
 	}
 }
+
+// CubeComplement is a view of a CubeSum in which every density v is
+// replaced by 0xFFFF - v, the "negative" density, like Complement is
+// for an AreaSummer.
+type CubeComplement struct {
+	*CubeSum
+}
+
+// At shows the last added frame, in negative.
+func (c CubeComplement) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(c.Rect)) || c.LenZ == 0 {
+		return color.Gray16{}
+	}
+	v := c.CubeSum.VolumeSum(image.Rect(x, y, x+1, y+1), c.LenZ-1, c.LenZ)
+	return color.Gray16{uint16(0xFFFF - v)}
+}
+
+// ValueAt returns the sum of the negative densities in the box from
+// (Rect.Min.X, Rect.Min.Y, 0) up to and including (x, y, z), or zero
+// outside of the cube.
+func (c CubeComplement) ValueAt(x, y, z int) uint64 {
+	if !(image.Point{x, y}.In(c.Rect)) || z < 0 || z >= c.LenZ {
+		return 0
+	}
+	volume := uint64(x+1-c.Rect.Min.X) * uint64(y+1-c.Rect.Min.Y) * uint64(z+1)
+	return volume*0xFFFF - c.CubeSum.ValueAt(x, y, z)
+}
+
+// VolumeSum sums the negative densities in the box defined by the
+// rectangle and zmin-zmax, with zmax clipped to the frames in the cube.
+func (c CubeComplement) VolumeSum(r image.Rectangle, zmin, zmax int) uint64 {
+	r = r.Intersect(c.Rect)
+	if zmax > c.LenZ {
+		zmax = c.LenZ
+	}
+	volume := uint64(r.Dx()) * uint64(r.Dy()) * uint64(zmax-zmin)
+	return volume*0xFFFF - c.CubeSum.VolumeSum(r, zmin, zmax)
+}
+
+// FindCx returns the x closest to the plane that divides the negative
+// mass of the box defined by r and zmin-zmax in half.
+func (c CubeComplement) FindCx(r image.Rectangle, zmin, zmax int) int {
+	return c.FindQx(r, zmin, zmax, 0.5)
+}
+
+// FindCy returns the y closest to the plane that divides the negative
+// mass of the box defined by r and zmin-zmax in half.
+func (c CubeComplement) FindCy(r image.Rectangle, zmin, zmax int) int {
+	return c.FindQy(r, zmin, zmax, 0.5)
+}
+
+// FindCz returns the z closest to the plane that divides the negative
+// mass of the box defined by r and zmin-zmax in half.
+func (c CubeComplement) FindCz(r image.Rectangle, zmin, zmax int) int {
+	return c.FindQz(r, zmin, zmax, 0.5)
+}
+
+// FindQx returns the x closest to the plane that puts fraction of the
+// negative mass of the box defined by r and zmin-zmax on its left.
+func (c CubeComplement) FindQx(r image.Rectangle, zmin, zmax int, fraction float64) int {
+	zmin, zmax = c.zRange(zmin, zmax)
+	return findQx3(c.Rect.Intersect(r), zmin, zmax, fraction, c.VolumeSum)
+}
+
+// FindQy returns the y closest to the plane that puts fraction of the
+// negative mass of the box defined by r and zmin-zmax above it.
+func (c CubeComplement) FindQy(r image.Rectangle, zmin, zmax int, fraction float64) int {
+	zmin, zmax = c.zRange(zmin, zmax)
+	return findQy3(c.Rect.Intersect(r), zmin, zmax, fraction, c.VolumeSum)
+}
+
+// FindQz returns the z closest to the plane that puts fraction of the
+// negative mass of the box defined by r and zmin-zmax in the frames
+// before it.
+func (c CubeComplement) FindQz(r image.Rectangle, zmin, zmax int, fraction float64) int {
+	zmin, zmax = c.zRange(zmin, zmax)
+	return findQz3(c.Rect.Intersect(r), zmin, zmax, fraction, c.VolumeSum)
+}
@@ -0,0 +1,130 @@
+package density
+
+import (
+	"image"
+	"image/color"
+)
+
+// rgbaFunc returns a Model that converts colours by f, from the
+// channels that their RGBA method returns. All predefined models are
+// made this way, which lets the constructors of the maps skip the
+// color.Color of every pixel for the common image types.
+func rgbaFunc(f func(r, g, b, a uint32) uint16) Model {
+	return &rgbaModel{f}
+}
+
+type rgbaModel struct {
+	f func(r, g, b, a uint32) uint16
+}
+
+func (m *rgbaModel) Convert(c color.Color) (d uint16) {
+	r, g, b, a := c.RGBA()
+	return m.f(r, g, b, a)
+}
+
+// channels returns the density function of m in terms of the channels
+// that RGBA returns, or nil if m is not a predefined model, or one of
+// the alpha and tone models wrapping one.
+func channels(m Model) func(r, g, b, a uint32) uint16 {
+	switch m := m.(type) {
+	case *rgbaModel:
+		return m.f
+	case *table:
+		if f := channels(m.m); f != nil {
+			t := m.t
+			return func(r, g, b, a uint32) uint16 { return t[f(r, g, b, a)] }
+		}
+	case *unpremultiplied:
+		if f := channels(m.m); f != nil {
+			return func(r, g, b, a uint32) uint16 { return f(unpremultiply(r, g, b, a)) }
+		}
+	case *composited:
+		if f := channels(m.m); f != nil {
+			return func(r, g, b, a uint32) uint16 { return f(m.over(r, g, b, a)) }
+		}
+	}
+	return nil
+}
+
+// densities returns a function that gives the density of the pixel at
+// (x, y) of i according to d. The Field of a FieldModel is determined
+// once, up front.
+//
+// For the common image types, the pixels are read directly. Gray and
+// Paletted images go through a lookup table of the densities of all
+// their colours; for the others, the predefined models are evaluated
+// on the channels straight away. Any other model is given the same
+// colour as At would give, so the densities are identical either way.
+func densities(i image.Image, d Model) func(x, y int) uint16 {
+	if f, ok := d.(FieldModel); ok {
+		m := f.Field(i)
+		return func(x, y int) uint16 { return m.Values[m.DVOffSet(x, y)] }
+	}
+
+	f := channels(d)
+	switch i := i.(type) {
+	case *image.Gray:
+		var lut [0x100]uint16
+		for v := range lut {
+			lut[v] = d.Convert(color.Gray{uint8(v)})
+		}
+		return func(x, y int) uint16 { return lut[i.Pix[i.PixOffset(x, y)]] }
+	case *image.Paletted:
+		lut := make([]uint16, len(i.Palette))
+		for k, c := range i.Palette {
+			lut[k] = d.Convert(c)
+		}
+		return func(x, y int) uint16 { return lut[i.Pix[i.PixOffset(x, y)]] }
+	case *image.Gray16:
+		if f != nil {
+			return func(x, y int) uint16 {
+				k := i.PixOffset(x, y)
+				v := uint32(i.Pix[k])<<8 | uint32(i.Pix[k+1])
+				return f(v, v, v, 0xFFFF)
+			}
+		}
+		return func(x, y int) uint16 {
+			k := i.PixOffset(x, y)
+			return d.Convert(color.Gray16{uint16(i.Pix[k])<<8 | uint16(i.Pix[k+1])})
+		}
+	case *image.RGBA:
+		if f != nil {
+			return func(x, y int) uint16 {
+				s := i.Pix[i.PixOffset(x, y):]
+				r, g, b, a := uint32(s[0]), uint32(s[1]), uint32(s[2]), uint32(s[3])
+				return f(r|r<<8, g|g<<8, b|b<<8, a|a<<8)
+			}
+		}
+		return func(x, y int) uint16 {
+			s := i.Pix[i.PixOffset(x, y):]
+			return d.Convert(color.RGBA{s[0], s[1], s[2], s[3]})
+		}
+	case *image.NRGBA:
+		if f != nil {
+			return func(x, y int) uint16 {
+				s := i.Pix[i.PixOffset(x, y):]
+				r, g, b, a := uint32(s[0]), uint32(s[1]), uint32(s[2]), uint32(s[3])
+				r = (r | r<<8) * a / 0xFF
+				g = (g | g<<8) * a / 0xFF
+				b = (b | b<<8) * a / 0xFF
+				return f(r, g, b, a|a<<8)
+			}
+		}
+		return func(x, y int) uint16 {
+			s := i.Pix[i.PixOffset(x, y):]
+			return d.Convert(color.NRGBA{s[0], s[1], s[2], s[3]})
+		}
+	case *image.YCbCr:
+		if f != nil {
+			return func(x, y int) uint16 {
+				c := i.COffset(x, y)
+				return f(color.YCbCr{i.Y[i.YOffset(x, y)], i.Cb[c], i.Cr[c]}.RGBA())
+			}
+		}
+		return func(x, y int) uint16 {
+			c := i.COffset(x, y)
+			return d.Convert(color.YCbCr{i.Y[i.YOffset(x, y)], i.Cb[c], i.Cr[c]})
+		}
+	}
+	return func(x, y int) uint16 { return d.Convert(i.At(x, y)) }
+}
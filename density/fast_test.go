@@ -0,0 +1,125 @@
+package density
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// plain hides the type of an image, so that the constructors can only
+// read it through At.
+type plain struct {
+	image.Image
+}
+
+// testImages returns an image of every type with a fast path, all
+// with odd bounds away from the origin, and filled with random pixels.
+func testImages() []image.Image {
+	rnd := rand.New(rand.NewSource(1))
+	r := image.Rect(-3, 5, 26, 22)
+	byt := func() uint8 { return uint8(rnd.Intn(0x100)) }
+
+	gray := image.NewGray(r)
+	gray16 := image.NewGray16(r)
+	rgba := image.NewRGBA(r)
+	nrgba := image.NewNRGBA(r)
+	pal := color.Palette{color.Black, color.White, color.Transparent, color.RGBA{0x80, 0x20, 0x10, 0xC0}, color.NRGBA{0x12, 0xEF, 0x56, 0x40}, color.Gray16{0x1234}}
+	paletted := image.NewPaletted(r, pal)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			gray.SetGray(x, y, color.Gray{byt()})
+			gray16.SetGray16(x, y, color.Gray16{uint16(rnd.Intn(0x10000))})
+			a := byt()
+			rgba.SetRGBA(x, y, color.RGBA{uint8(rnd.Intn(int(a) + 1)), uint8(rnd.Intn(int(a) + 1)), uint8(rnd.Intn(int(a) + 1)), a})
+			nrgba.SetNRGBA(x, y, color.NRGBA{byt(), byt(), byt(), byt()})
+			paletted.SetColorIndex(x, y, uint8(rnd.Intn(len(pal))))
+		}
+	}
+	is := []image.Image{gray, gray16, rgba, nrgba, paletted}
+	for _, s := range []image.YCbCrSubsampleRatio{image.YCbCrSubsampleRatio444, image.YCbCrSubsampleRatio420} {
+		ycc := image.NewYCbCr(r, s)
+		for _, p := range [][]uint8{ycc.Y, ycc.Cb, ycc.Cr} {
+			for k := range p {
+				p[k] = byt()
+			}
+		}
+		is = append(is, ycc)
+	}
+	return is
+}
+
+// testModels returns predefined models, models wrapping them, and a
+// model that has no fast path.
+func testModels() []Model {
+	return []Model{
+		AvgDensity,
+		HueDensity,
+		NegLabBDensity,
+		Gamma(AvgDensity, 0.5),
+		Unpremultiply(HSLLightnessDensity),
+		Over(KeyDensity, color.RGBA{0x10, 0x80, 0xF0, 0xFF}),
+		ModelFunc(func(c color.Color) uint16 {
+			r, _, b, a := c.RGBA()
+			return uint16((r + 2*b + a) / 4)
+		}),
+	}
+}
+
+func TestDensities(t *testing.T) {
+	for _, i := range testImages() {
+		for k, m := range testModels() {
+			at := densities(i, m)
+			r := i.Bounds()
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					if got, want := at(x, y), m.Convert(i.At(x, y)); got != want {
+						t.Fatalf("%T, model %d: density at (%d, %d) = %#x, want %#x", i, k, x, y, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestFastPaths(t *testing.T) {
+	for _, i := range testImages() {
+		for k, m := range testModels() {
+			p := plain{i}
+			for _, test := range []struct {
+				name      string
+				got, want interface{}
+			}{
+				{"Map", MapFrom(i, m), MapFrom(p, m)},
+				{"SumX", SumXFrom(i, m), SumXFrom(p, m)},
+				{"SumY", SumYFrom(i, m), SumYFrom(p, m)},
+				{"Sum", SumFrom(i, m), SumFrom(p, m)},
+				{"DSum", DSumFrom(i, m), DSumFrom(p, m)},
+				{"MomentSum", MomentSumFrom(i, m), MomentSumFrom(p, m)},
+				{"CubeSum", cubeSumOf(i, m), cubeSumOf(p, m)},
+			} {
+				if !reflect.DeepEqual(test.got, test.want) {
+					t.Errorf("%T, model %d: %s differs from the one built through At", i, k, test.name)
+				}
+			}
+		}
+	}
+}
+
+// cubeSumOf returns a CubeSum of two frames, i and i upside down.
+func cubeSumOf(i image.Image, m Model) *CubeSum {
+	cbs := CubeSumFrom(i, m, 2)
+	cbs.AddFrame(flipped{i}, m)
+	return cbs
+}
+
+// flipped is an image turned upside down.
+type flipped struct {
+	image.Image
+}
+
+func (f flipped) At(x, y int) color.Color {
+	r := f.Bounds()
+	return f.Image.At(x, r.Max.Y-1-(y-r.Min.Y))
+}
@@ -0,0 +1,164 @@
+package density
+
+import (
+	"image"
+	"image/color"
+)
+
+// FenwickSum is a DSum for densities that keep changing. Where
+// DSum.Set has to update the entire area from (x,y) to the bottom
+// right, FenwickSum stores its sums in a two-dimensional binary indexed
+// (Fenwick) tree. Both Set and looking up a sum then visit about
+// log(w)·log(h) entries, for a map of w by h pixels.
+type FenwickSum struct {
+	// Values holds the map's density values. The value at (x, y)
+	// starts at Values[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)*1].
+	Values []uint16
+	// Stride is the Values' stride between
+	// vertically adjacent pixels.
+	Stride int
+	// Rect is the Map's bounds.
+	Rect image.Rectangle
+	// tree holds the partial sums, in the same layout as Values.
+	tree []uint64
+}
+
+func (d *FenwickSum) ColorModel() color.Model {
+	return color.Gray16Model
+}
+
+func (d *FenwickSum) Copy(s *FenwickSum) {
+	d.Values = make([]uint16, len(s.Values), cap(s.Values))
+	copy(d.Values, s.Values)
+	d.tree = make([]uint64, len(s.tree), cap(s.tree))
+	copy(d.tree, s.tree)
+	d.Stride = s.Stride
+	d.Rect = s.Rect
+}
+
+func (d *FenwickSum) DVOffSet(x, y int) int {
+	return (y-d.Rect.Min.Y)*d.Stride + (x - d.Rect.Min.X)
+}
+
+func (d *FenwickSum) Bounds() image.Rectangle { return d.Rect }
+
+func (d *FenwickSum) At(x, y int) (v color.Color) {
+	if (image.Point{x, y}.In(d.Rect)) {
+		v = color.Gray16{d.Values[d.DVOffSet(x, y)]}
+	}
+	return
+}
+
+// ValueAt returns the sum of all the values in the rectangle from
+// Rect.Min up to and including (x, y), like DSum.ValueAt.
+func (d *FenwickSum) ValueAt(x, y int) (v uint64) {
+	if !(image.Point{x, y}.In(d.Rect)) {
+		return
+	}
+	// The tree is indexed from one, so that i&-i gives the number
+	// of values that the entry at i sums along each axis.
+	for j := y - d.Rect.Min.Y + 1; j > 0; j -= j & -j {
+		row := d.tree[(j-1)*d.Stride:]
+		for i := x - d.Rect.Min.X + 1; i > 0; i -= i & -i {
+			v += row[i-1]
+		}
+	}
+	return
+}
+
+// Set sets the density at (x, y) to v. It updates about log(w)·log(h)
+// entries of the tree, for a map of w by h pixels.
+func (d *FenwickSum) Set(x, y int, v uint16) {
+	if !(image.Point{x, y}.In(d.Rect)) {
+		return
+	}
+	k := d.DVOffSet(x, y)
+	dv := uint64(v) - uint64(d.Values[k])
+	d.Values[k] = v
+	w, h := d.Rect.Dx(), d.Rect.Dy()
+	for j := y - d.Rect.Min.Y + 1; j <= h; j += j & -j {
+		row := d.tree[(j-1)*d.Stride:]
+		for i := x - d.Rect.Min.X + 1; i <= w; i += i & -i {
+			row[i-1] += dv
+		}
+	}
+}
+
+// AreaSum returns the sum of the densities in r, from r.Min up to but
+// not including r.Max, from four lookups of ValueAt.
+func (d *FenwickSum) AreaSum(r image.Rectangle) uint64 {
+	r = r.Intersect(d.Rect)
+	return d.ValueAt(r.Max.X-1, r.Max.Y-1) +
+		d.ValueAt(r.Min.X-1, r.Min.Y-1) -
+		d.ValueAt(r.Min.X-1, r.Max.Y-1) -
+		d.ValueAt(r.Max.X-1, r.Min.Y-1)
+}
+
+// FindCx returns the x closest to the vertical line that divides the
+// mass of r in half, with r clipped to the bounds of d.
+func (d *FenwickSum) FindCx(r image.Rectangle) int {
+	return d.FindQx(r, 0.5)
+}
+
+// FindCy returns the y closest to the horizontal line that divides
+// the mass of r in half, with r clipped to the bounds of d.
+func (d *FenwickSum) FindCy(r image.Rectangle) int {
+	return d.FindQy(r, 0.5)
+}
+
+// FindQx returns the x closest to the vertical line that puts fraction
+// of the mass of r on its left, with r clipped to the bounds of d.
+func (d *FenwickSum) FindQx(r image.Rectangle, fraction float64) int {
+	return areaFindQx(d, r, fraction)
+}
+
+// FindQy returns the y closest to the horizontal line that puts
+// fraction of the mass of r above it, with r clipped to the bounds
+// of d.
+func (d *FenwickSum) FindQy(r image.Rectangle, fraction float64) int {
+	return areaFindQy(d, r, fraction)
+}
+
+// NewFenwickSum returns an empty FenwickSum of the given dimensions.
+func NewFenwickSum(r image.Rectangle) *FenwickSum {
+	w, h := r.Dx(), r.Dy()
+	return &FenwickSum{
+		Values: make([]uint16, w*h),
+		Stride: w,
+		Rect:   r,
+		tree:   make([]uint64, w*h),
+	}
+}
+
+// FenwickSumFrom returns a FenwickSum of the densities of i according
+// to d. The tree is built in time proportional to the area of i.
+func FenwickSumFrom(i image.Image, d Model) *FenwickSum {
+	r := i.Bounds()
+	at := densities(i, d)
+	w, h := r.Dx(), r.Dy()
+	fs := NewFenwickSum(r)
+
+	// Every entry adds itself to the next entry that covers it,
+	// first along the rows, then along the columns, which builds
+	// the tree in linear time.
+	for y := 0; y < h; y++ {
+		row := fs.tree[y*w : (y+1)*w]
+		for x := 0; x < w; x++ {
+			v := at(x+r.Min.X, y+r.Min.Y)
+			fs.Values[x+y*w] = v
+			row[x] += uint64(v)
+			if p := x + 1 + (x+1)&-(x+1); p <= w {
+				row[p-1] += row[x]
+			}
+		}
+	}
+	for y := 0; y < h; y++ {
+		if p := y + 1 + (y+1)&-(y+1); p <= h {
+			row, parent := fs.tree[y*w:(y+1)*w], fs.tree[(p-1)*w:p*w]
+			for x := range row {
+				parent[x] += row[x]
+			}
+		}
+	}
+	return fs
+}
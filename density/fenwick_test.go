@@ -0,0 +1,64 @@
+package density
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+func TestFenwickSum(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, r := range []image.Rectangle{
+		image.Rect(0, 0, 16, 8),
+		image.Rect(-3, 5, 26, 22),
+		image.Rect(7, -4, 8, 13),
+		image.Rect(2, 3, 3, 4),
+	} {
+		img := image.NewRGBA(r)
+		rnd.Read(img.Pix)
+		ds := DSumFrom(img, AvgDensity)
+		fs := FenwickSumFrom(img, AvgDensity)
+		check := func(when string) {
+			for y := r.Min.Y - 1; y <= r.Max.Y; y++ {
+				for x := r.Min.X - 1; x <= r.Max.X; x++ {
+					if got, want := fs.ValueAt(x, y), ds.ValueAt(x, y); got != want {
+						t.Fatalf("%v %s: ValueAt(%d, %d) = %d, want %d", r, when, x, y, got, want)
+					}
+				}
+			}
+			for _, q := range testRects(r) {
+				if got, want := fs.AreaSum(q), ds.AreaSum(q); got != want {
+					t.Errorf("%v %s: AreaSum(%v) = %d, want %d", r, when, q, got, want)
+				}
+				if got, want := fs.FindCx(q), ds.FindCx(q); got != want {
+					t.Errorf("%v %s: FindCx(%v) = %d, want %d", r, when, q, got, want)
+				}
+				if got, want := fs.FindCy(q), ds.FindCy(q); got != want {
+					t.Errorf("%v %s: FindCy(%v) = %d, want %d", r, when, q, got, want)
+				}
+			}
+		}
+		check("from image")
+
+		// Random values go both up and down, so that the change
+		// added to the tree wraps around as often as not.
+		for k := 0; k < 50; k++ {
+			x, y := r.Min.X+rnd.Intn(r.Dx()), r.Min.Y+rnd.Intn(r.Dy())
+			v := uint16(rnd.Intn(0x10000))
+			switch k % 10 {
+			case 0:
+				v = 0
+			case 1:
+				v = 0xFFFF
+			}
+			ds.Set(x, y, v)
+			fs.Set(x, y, v)
+			if got := fs.At(x, y); got != ds.At(x, y) {
+				t.Fatalf("%v: At(%d, %d) = %v after Set to %d", r, x, y, got, v)
+			}
+		}
+		fs.Set(r.Max.X, r.Min.Y, 0xFFFF)
+		fs.Set(r.Min.X-1, r.Max.Y-1, 0xFFFF)
+		check("after Set")
+	}
+}
@@ -0,0 +1,204 @@
+package density
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// A FieldModel gives densities that depend on the neighbourhood of a
+// pixel, rather than on its colour alone. MapFrom and the constructors
+// of the summed maps accept a FieldModel wherever they take a Model,
+// and then use its Field instead of converting every pixel.
+//
+// Convert gives the density of a colour within a neighbourhood of the
+// same colour, which for edge and contrast models is zero.
+type FieldModel interface {
+	Model
+	// Field returns the densities of all pixels of i, as a new Map
+	// with the same bounds as i.
+	Field(i image.Image) *Map
+}
+
+// fieldMap returns an empty Map with bounds r, which may be empty.
+func fieldMap(r image.Rectangle) *Map {
+	return &Map{Values: make([]uint16, r.Dx()*r.Dy()), Stride: r.Dx(), Rect: r}
+}
+
+// clampedAt returns the density of m at (x, y), or that of the nearest
+// pixel inside m if (x, y) lies outside of it.
+func clampedAt(m *Map, x, y int) float64 {
+	r := m.Rect
+	switch {
+	case x < r.Min.X:
+		x = r.Min.X
+	case x >= r.Max.X:
+		x = r.Max.X - 1
+	}
+	switch {
+	case y < r.Min.Y:
+		y = r.Min.Y
+	case y >= r.Max.Y:
+		y = r.Max.Y - 1
+	}
+	return float64(m.Values[m.DVOffSet(x, y)])
+}
+
+// Sobel returns a FieldModel that gives the magnitude of the gradient
+// of the densities of m, as found by the Sobel operator. A sharp edge
+// from zero to full density gives full density.
+func Sobel(m Model) FieldModel {
+	return &sobel{m}
+}
+
+type sobel struct {
+	m Model
+}
+
+func (s *sobel) Convert(c color.Color) (d uint16) { return 0 }
+
+func (s *sobel) Field(i image.Image) *Map {
+	src := MapFrom(i, s.m)
+	r := src.Rect
+	f := fieldMap(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			at := func(dx, dy int) float64 { return clampedAt(src, x+dx, y+dy) }
+			gx := at(1, -1) + 2*at(1, 0) + at(1, 1) - at(-1, -1) - 2*at(-1, 0) - at(-1, 1)
+			gy := at(-1, 1) + 2*at(0, 1) + at(1, 1) - at(-1, -1) - 2*at(0, -1) - at(1, -1)
+			f.InitSet(x, y, unit(math.Hypot(gx, gy)/(4*0xFFFF)))
+		}
+	}
+	return f
+}
+
+// Laplacian returns a FieldModel that gives the magnitude of the
+// Laplacian of the densities of m, over the four nearest neighbours
+// of every pixel. A single pixel of full density on an empty
+// background gives full density.
+func Laplacian(m Model) FieldModel {
+	return &laplacian{m}
+}
+
+type laplacian struct {
+	m Model
+}
+
+func (l *laplacian) Convert(c color.Color) (d uint16) { return 0 }
+
+func (l *laplacian) Field(i image.Image) *Map {
+	src := MapFrom(i, l.m)
+	r := src.Rect
+	f := fieldMap(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			v := 4*clampedAt(src, x, y) - clampedAt(src, x-1, y) - clampedAt(src, x+1, y) -
+				clampedAt(src, x, y-1) - clampedAt(src, x, y+1)
+			f.InitSet(x, y, unit(math.Abs(v)/(4*0xFFFF)))
+		}
+	}
+	return f
+}
+
+// LocalContrast returns a FieldModel that gives the standard deviation
+// of the densities of m within radius pixels of every pixel, along
+// either axis. Near the border of the image, only the part of the
+// window inside it counts. Half of the window at zero and half at
+// full density gives full density.
+func LocalContrast(m Model, radius int) FieldModel {
+	if radius < 0 {
+		radius = 0
+	}
+	return &localContrast{m, radius}
+}
+
+type localContrast struct {
+	m      Model
+	radius int
+}
+
+func (l *localContrast) Convert(c color.Color) (d uint16) { return 0 }
+
+func (l *localContrast) Field(i image.Image) *Map {
+	src := MapFrom(i, l.m)
+	r := src.Rect
+	w, h := r.Dx(), r.Dy()
+	f := fieldMap(r)
+
+	// Summed-area tables of the densities and their squares, with
+	// an extra row and column of zeroes in front.
+	s := make([]uint64, (w+1)*(h+1))
+	s2 := make([]uint64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var row, row2 uint64
+		for x := 0; x < w; x++ {
+			v := uint64(src.Values[y*src.Stride+x])
+			row += v
+			row2 += v * v
+			k := (y+1)*(w+1) + x + 1
+			s[k] = row + s[k-w-1]
+			s2[k] = row2 + s2[k-w-1]
+		}
+	}
+	area := func(t []uint64, x0, y0, x1, y1 int) float64 {
+		return float64(t[y1*(w+1)+x1] + t[y0*(w+1)+x0] - t[y0*(w+1)+x1] - t[y1*(w+1)+x0])
+	}
+
+	for y := 0; y < h; y++ {
+		y0, y1 := y-l.radius, y+l.radius+1
+		if y0 < 0 {
+			y0 = 0
+		}
+		if y1 > h {
+			y1 = h
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := x-l.radius, x+l.radius+1
+			if x0 < 0 {
+				x0 = 0
+			}
+			if x1 > w {
+				x1 = w
+			}
+			n := float64((x1 - x0) * (y1 - y0))
+			mean := area(s, x0, y0, x1, y1) / n
+			v := area(s2, x0, y0, x1, y1)/n - mean*mean
+			f.InitSet(x+r.Min.X, y+r.Min.Y, unit(2*math.Sqrt(math.Max(v, 0))/0xFFFF))
+		}
+	}
+	return f
+}
+
+// Blend returns a FieldModel that mixes the densities of a and b, each
+// of which may be a FieldModel or an ordinary Model: t of b, and the
+// rest of a. With an edge model as b, this gives densities that follow
+// the tones of an image, but with its edges emphasised.
+func Blend(a, b Model, t float64) FieldModel {
+	return &blend{a, b, t}
+}
+
+type blend struct {
+	a, b Model
+	t    float64
+}
+
+func (b *blend) mix(va, vb uint16) uint16 {
+	return unit(((1-b.t)*float64(va) + b.t*float64(vb)) / 0xFFFF)
+}
+
+func (b *blend) Convert(c color.Color) (d uint16) {
+	return b.mix(b.a.Convert(c), b.b.Convert(c))
+}
+
+func (b *blend) Field(i image.Image) *Map {
+	ma, mb := MapFrom(i, b.a), MapFrom(i, b.b)
+	r := ma.Rect
+	f := fieldMap(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			k := ma.DVOffSet(x, y)
+			f.InitSet(x, y, b.mix(ma.Values[k], mb.Values[k]))
+		}
+	}
+	return f
+}
@@ -0,0 +1,75 @@
+package density
+
+import (
+	"image"
+)
+
+// An AreaSummer is a density map that can sum its densities over a
+// rectangle, and find the lines that divide the mass of a rectangle
+// in half, or into any fraction of it on one side and the rest on the
+// other. The rectangles run from r.Min up to but not including r.Max,
+// and are clipped to the bounds of the map.
+//
+// All the two-dimensional maps in this package are AreaSummers, so
+// algorithms written against it work with whichever map suits the
+// image best. They differ in how fast they are: a Map sums every
+// pixel, SumX, SumY and Sum every row or column, DSum and the types
+// built on it take constant time, and FenwickSum takes logarithmic
+// time, but changes its densities quickly as well.
+type AreaSummer interface {
+	image.Image
+	AreaSum(r image.Rectangle) uint64
+	FindCx(r image.Rectangle) int
+	FindCy(r image.Rectangle) int
+	FindQx(r image.Rectangle, fraction float64) int
+	FindQy(r image.Rectangle, fraction float64) int
+}
+
+// A Mutable is a density map whose density at (x, y) can be set to v.
+// Points outside of its bounds are ignored. For a map of w by h
+// pixels, Set takes constant time for a Map, O(w) for a SumX, O(h)
+// for a SumY, O(w+h) for a Sum, O(w·h) for a DSum, MomentSum and
+// SecondMomentSum, and O(log w·log h) for a FenwickSum.
+type Mutable interface {
+	Set(x, y int, v uint16)
+}
+
+var (
+	_ AreaSummer = (*Map)(nil)
+	_ AreaSummer = (*SumX)(nil)
+	_ AreaSummer = (*SumY)(nil)
+	_ AreaSummer = (*Sum)(nil)
+	_ AreaSummer = (*DSum)(nil)
+	_ AreaSummer = (*MomentSum)(nil)
+	_ AreaSummer = (*SecondMomentSum)(nil)
+	_ AreaSummer = (*FenwickSum)(nil)
+	_ AreaSummer = Complement{}
+	_ AreaSummer = Scaled{}
+	_ AreaSummer = Offset{}
+	_ AreaSummer = Clipped{}
+
+	_ Mutable = (*Map)(nil)
+	_ Mutable = (*SumX)(nil)
+	_ Mutable = (*SumY)(nil)
+	_ Mutable = (*Sum)(nil)
+	_ Mutable = (*DSum)(nil)
+	_ Mutable = (*MomentSum)(nil)
+	_ Mutable = (*SecondMomentSum)(nil)
+	_ Mutable = (*FenwickSum)(nil)
+)
+
+// areaFindQx is FindQx for any AreaSummer, through its area sums.
+func areaFindQx(a AreaSummer, r image.Rectangle, fraction float64) int {
+	r = a.Bounds().Intersect(r)
+	return quantile(r.Min.X, r.Max.X, fraction, func(x int) uint64 {
+		return a.AreaSum(image.Rect(r.Min.X, r.Min.Y, x, r.Max.Y))
+	})
+}
+
+// areaFindQy is FindQy for any AreaSummer, through its area sums.
+func areaFindQy(a AreaSummer, r image.Rectangle, fraction float64) int {
+	r = a.Bounds().Intersect(r)
+	return quantile(r.Min.Y, r.Max.Y, fraction, func(y int) uint64 {
+		return a.AreaSum(image.Rect(r.Min.X, r.Min.Y, r.Max.X, y))
+	})
+}
@@ -19,6 +19,8 @@ When an algorithm requires summing the values over a large area
 of a Map, these maps can in theory do this faster by reducing the
 number of memory lookups needed. Take note that potential speed
 benefits also greatly depend on things like branch prediction.
+The other summed maps, and the views on them, are AreaSummers
+as well (see there).
 
 As these sums most likely overflow 16 bit values, they are
 stored internally as uint64. They still produce the same
@@ -35,6 +37,7 @@ package density
 import (
 	"image"
 	"image/color"
+	"sync"
 )
 
 // Map is a finite rectangular grid of density values, usually
@@ -74,6 +77,8 @@ func (d *Map) DVOffSet(x, y int) int {
 	return (y-d.Rect.Min.Y)*d.Stride + (x - d.Rect.Min.X)
 }
 
+// Set sets the density at (x, y) to v, and updates the mass and the
+// weighted x and y along with it, in constant time.
 func (d *Map) Set(x, y int, v uint16) {
 	if !(image.Point{x, y}.In(d.Rect)) {
 		return
@@ -140,6 +145,44 @@ func (d *Map) ValueAt(x, y int) (v uint64) {
 	return
 }
 
+// AreaSum returns the sum of the densities in r, from r.Min up to but
+// not including r.Max. It visits every pixel of r.
+func (d *Map) AreaSum(r image.Rectangle) (sum uint64) {
+	r = r.Intersect(d.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := d.DVOffSet(r.Min.X, y)
+		for _, v := range d.Values[i : i+r.Dx()] {
+			sum += uint64(v)
+		}
+	}
+	return
+}
+
+// FindCx returns the x closest to the vertical line that divides the
+// mass of r in half, with r clipped to the bounds of d.
+func (d *Map) FindCx(r image.Rectangle) int {
+	return d.FindQx(r, 0.5)
+}
+
+// FindCy returns the y closest to the horizontal line that divides
+// the mass of r in half, with r clipped to the bounds of d.
+func (d *Map) FindCy(r image.Rectangle) int {
+	return d.FindQy(r, 0.5)
+}
+
+// FindQx returns the x closest to the vertical line that puts fraction
+// of the mass of r on its left, with r clipped to the bounds of d.
+func (d *Map) FindQx(r image.Rectangle, fraction float64) int {
+	return areaFindQx(d, r, fraction)
+}
+
+// FindQy returns the y closest to the horizontal line that puts
+// fraction of the mass of r above it, with r clipped to the bounds
+// of d.
+func (d *Map) FindQy(r image.Rectangle, fraction float64) int {
+	return areaFindQy(d, r, fraction)
+}
+
 // CM returns the centre of mass of the Map.
 func (d *Map) CM() (x, y float64) {
 	x = float64(d.Rect.Min.X) + (float64(d.wx) / float64(d.mass))
@@ -183,17 +226,38 @@ func NewMap(r image.Rectangle) (d *Map) {
 }
 
 // Determines the density values of image.Image according to the density
-// model it is given, and returns the results as a new Map.
+// model it is given, and returns the results as a new Map. For a
+// FieldModel, that is its Field.
 func MapFrom(i image.Image, d Model) *Map {
+	return MapFromN(i, d, 1)
+}
+
+// MapFromN is like MapFrom, but converts the rows of i on n goroutines.
+func MapFromN(i image.Image, d Model, n int) *Map {
+	if f, ok := d.(FieldModel); ok {
+		return f.Field(i)
+	}
 	r := i.Bounds()
 	w, h := r.Dx(), r.Dy()
 	dv := make([]uint16, w*h)
 	dm := Map{Values: dv, Stride: w, Rect: r}
-	for y := r.Min.Y; y < r.Max.Y; y++ {
-		for x := r.Min.X; x < r.Max.X; x++ {
-			dm.InitSet(x, y, d.Convert(i.At(x, y)))
+	at := densities(i, d)
+	var mu sync.Mutex
+	parallel(h, n, func(lo, hi int) {
+		// Every part of the rows sums its own mass, weighed x
+		// and weighed y, which are all added up in the end.
+		part := Map{Values: dv[lo*w : hi*w], Stride: w, Rect: image.Rect(r.Min.X, r.Min.Y+lo, r.Max.X, r.Min.Y+hi)}
+		for y := part.Rect.Min.Y; y < part.Rect.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				part.InitSet(x, y, at(x, y))
+			}
 		}
-	}
+		mu.Lock()
+		dm.mass += part.mass
+		dm.wx += part.wx
+		dm.wy += part.wy + part.mass*uint64(lo)
+		mu.Unlock()
+	})
 	return &dm
 }
 
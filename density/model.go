@@ -23,76 +23,68 @@ func (m *modelFunc) Convert(c color.Color) (d uint16) {
 }
 
 // Default models for density functions. These all linearly map their
-// respective channels to a density value.
+// respective channels to a density value. The channels are premultiplied
+// by alpha, as returned by RGBA; see Unpremultiply and Over for models
+// that treat transparency otherwise.
 var (
-	AvgDensity      Model = ModelFunc(avgDensity)
-	RedDensity      Model = ModelFunc(redDensity)
-	GreenDensity    Model = ModelFunc(greenDensity)
-	BlueDensity     Model = ModelFunc(blueDensity)
-	AlphaDensity    Model = ModelFunc(alphaDensity)
-	NegAvgDensity   Model = ModelFunc(negAvgDensity)
-	NegRedDensity   Model = ModelFunc(negRedDensity)
-	NegGreenDensity Model = ModelFunc(negGreenDensity)
-	NegBlueDensity  Model = ModelFunc(negBlueDensity)
-	NegAlphaDensity Model = ModelFunc(negAlphaDensity)
+	AvgDensity      Model = rgbaFunc(avgDensity)
+	RedDensity      Model = rgbaFunc(redDensity)
+	GreenDensity    Model = rgbaFunc(greenDensity)
+	BlueDensity     Model = rgbaFunc(blueDensity)
+	AlphaDensity    Model = rgbaFunc(alphaDensity)
+	NegAvgDensity   Model = rgbaFunc(negAvgDensity)
+	NegRedDensity   Model = rgbaFunc(negRedDensity)
+	NegGreenDensity Model = rgbaFunc(negGreenDensity)
+	NegBlueDensity  Model = rgbaFunc(negBlueDensity)
+	NegAlphaDensity Model = rgbaFunc(negAlphaDensity)
 )
 
-func avgDensity(c color.Color) (d uint16) {
-	r, g, b, _ := c.RGBA()
+func avgDensity(r, g, b, _ uint32) (d uint16) {
 	d = uint16((r + g + b + 1) / 3)
 	return
 }
 
-func redDensity(c color.Color) (d uint16) {
-	r, _, _, _ := c.RGBA()
+func redDensity(r, _, _, _ uint32) (d uint16) {
 	d = uint16(r)
 	return
 }
 
-func greenDensity(c color.Color) (d uint16) {
-	_, g, _, _ := c.RGBA()
+func greenDensity(_, g, _, _ uint32) (d uint16) {
 	d = uint16(g)
 	return
 }
 
-func blueDensity(c color.Color) (d uint16) {
-	_, _, b, _ := c.RGBA()
+func blueDensity(_, _, b, _ uint32) (d uint16) {
 	d = uint16(b)
 	return
 }
 
-func alphaDensity(c color.Color) (d uint16) {
-	_, _, _, a := c.RGBA()
+func alphaDensity(_, _, _, a uint32) (d uint16) {
 	d = uint16(a)
 	return
 }
 
-func negAvgDensity(c color.Color) (d uint16) {
-	r, g, b, _ := c.RGBA()
+func negAvgDensity(r, g, b, _ uint32) (d uint16) {
 	d = uint16(0xFFFF - (r+g+b)/3)
 	return
 }
 
-func negRedDensity(c color.Color) (d uint16) {
-	r, _, _, _ := c.RGBA()
+func negRedDensity(r, _, _, _ uint32) (d uint16) {
 	d = uint16(0xFFFF - r)
 	return
 }
 
-func negGreenDensity(c color.Color) (d uint16) {
-	_, g, _, _ := c.RGBA()
+func negGreenDensity(_, g, _, _ uint32) (d uint16) {
 	d = uint16(0xFFFF - g)
 	return
 }
 
-func negBlueDensity(c color.Color) (d uint16) {
-	_, _, b, _ := c.RGBA()
+func negBlueDensity(_, _, b, _ uint32) (d uint16) {
 	d = uint16(0xFFFF - b)
 	return
 }
 
-func negAlphaDensity(c color.Color) (d uint16) {
-	_, _, _, a := c.RGBA()
+func negAlphaDensity(_, _, _, a uint32) (d uint16) {
 	d = uint16(0xFFFF - a)
 	return
 }
@@ -0,0 +1,131 @@
+package density
+
+import (
+	"image"
+)
+
+// MomentSum is a DSum that also holds the double sums of the densities
+// weighed by their x and y, the first moments. With those, the mass
+// and centre of mass of any rectangle take constant time to find.
+type MomentSum struct {
+	DSum
+	// WX and WY hold the double sums of the densities weighed by
+	// their x and y, in the same layout as Values. Like Map.WX and
+	// Map.WY, these are not bounds-corrected.
+	WX, WY []uint64
+}
+
+func (d *MomentSum) Copy(s *MomentSum) {
+	d.DSum.Copy(&s.DSum)
+	d.WX = make([]uint64, len(s.WX), cap(s.WX))
+	copy(d.WX, s.WX)
+	d.WY = make([]uint64, len(s.WY), cap(s.WY))
+	copy(d.WY, s.WY)
+}
+
+// Like DSum.Set, this has to update the entire area from (x,y) to the
+// bottom right, but does so for the moments as well. It takes time
+// proportional to the area of the map.
+func (d *MomentSum) Set(x, y int, v uint16) {
+	if !(image.Point{x, y}.In(d.Rect)) {
+		return
+	}
+	dv := uint64(v) - d.AreaMass(image.Rect(x, y, x+1, y+1))
+	dx := dv * uint64(x-d.Rect.Min.X)
+	dy := dv * uint64(y-d.Rect.Min.Y)
+	for j := y; j < d.Rect.Max.Y; j++ {
+		for i := x; i < d.Rect.Max.X; i++ {
+			k := d.DVOffSet(i, j)
+			d.Values[k] += dv
+			d.WX[k] += dx
+			d.WY[k] += dy
+		}
+	}
+}
+
+// area sums the values in t, in the layout of Values, over the
+// rectangle r from r.Min up to but not including r.Max.
+func (d *MomentSum) area(t []uint64, r image.Rectangle) uint64 {
+	r = r.Intersect(d.Rect)
+	if r.Empty() {
+		return 0
+	}
+	at := func(x, y int) (v uint64) {
+		if x >= d.Rect.Min.X && y >= d.Rect.Min.Y {
+			v = t[d.DVOffSet(x, y)]
+		}
+		return
+	}
+	return at(r.Max.X-1, r.Max.Y-1) +
+		at(r.Min.X-1, r.Min.Y-1) -
+		at(r.Min.X-1, r.Max.Y-1) -
+		at(r.Max.X-1, r.Min.Y-1)
+}
+
+// AreaMass returns the mass of the rectangle r, from r.Min up to but
+// not including r.Max. This is the same as AreaSum.
+func (d *MomentSum) AreaMass(r image.Rectangle) uint64 {
+	return d.area(d.Values, r)
+}
+
+// AreaMoments returns the mass and the weighted x and y of the
+// rectangle r. Like Map.WX and Map.WY, the weighted x and y are not
+// bounds-corrected.
+func (d *MomentSum) AreaMoments(r image.Rectangle) (mass, wx, wy uint64) {
+	return d.area(d.Values, r), d.area(d.WX, r), d.area(d.WY, r)
+}
+
+// AreaCM returns the centre of mass of the rectangle r, taking pixel
+// (x, y) to cover the unit square from (x, y) up to (x+1, y+1), as
+// SubCM does. Its centre is at (x+0.5, y+0.5), so the result is half
+// a pixel to the right of and below that of Map.CM for the same
+// densities. If r has no mass, the centre of r itself is returned
+// instead.
+func (d *MomentSum) AreaCM(r image.Rectangle) (x, y float64) {
+	mass, wx, wy := d.AreaMoments(r)
+	if mass == 0 {
+		return float64(r.Min.X+r.Max.X) / 2, float64(r.Min.Y+r.Max.Y) / 2
+	}
+	x = float64(d.Rect.Min.X) + 0.5 + float64(wx)/float64(mass)
+	y = float64(d.Rect.Min.Y) + 0.5 + float64(wy)/float64(mass)
+	return
+}
+
+func NewMomentSum(r image.Rectangle) *MomentSum {
+	w, h := r.Dx(), r.Dy()
+	return &MomentSum{
+		DSum: DSum{Values: make([]uint64, w*h), Stride: w, Rect: r},
+		WX:   make([]uint64, w*h),
+		WY:   make([]uint64, w*h),
+	}
+}
+
+func MomentSumFrom(i image.Image, d Model) *MomentSum {
+	return MomentSumFromN(i, d, 1)
+}
+
+// MomentSumFromN is like MomentSumFrom, but sums the rows and then
+// the columns of i on n goroutines.
+func MomentSumFromN(i image.Image, d Model, n int) *MomentSum {
+	r := i.Bounds()
+	at := densities(i, d)
+	w, h := r.Dx(), r.Dy()
+	ms := NewMomentSum(r)
+	parallel(h, n, func(lo, hi int) {
+		for y := lo; y < hi; y++ {
+			var v, vx, vy uint64
+			for x := 0; x < w; x++ {
+				dv := uint64(at(x+r.Min.X, y+r.Min.Y))
+				v += dv
+				vx += dv * uint64(x)
+				vy += dv * uint64(y)
+				k := x + y*w
+				ms.Values[k], ms.WX[k], ms.WY[k] = v, vx, vy
+			}
+		}
+	})
+	addRows(ms.Values, w, h, n)
+	addRows(ms.WX, w, h, n)
+	addRows(ms.WY, w, h, n)
+	return ms
+}
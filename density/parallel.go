@@ -0,0 +1,90 @@
+package density
+
+import (
+	"image"
+	"runtime"
+	"sync"
+)
+
+// The constructors ending in N build their maps on n goroutines, which
+// gives the same values as building them on one. With n below one,
+// they use as many goroutines as runtime.GOMAXPROCS allows to run at
+// once. The Model has to be safe for concurrent use, which all the
+// predefined models are.
+
+// parallel splits [0, size) into contiguous parts, one for each of
+// the workers but no more than size, and calls f on every part in a
+// goroutine of its own. It returns once all calls have returned.
+func parallel(size, workers int, f func(lo, hi int)) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > size {
+		workers = size
+	}
+	if workers < 2 {
+		f(0, size)
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for k := 0; k < workers; k++ {
+		lo, hi := k*size/workers, (k+1)*size/workers
+		go func() {
+			defer wg.Done()
+			f(lo, hi)
+		}()
+	}
+	wg.Wait()
+}
+
+// sumRows fills dv with the sums along the rows of the densities over
+// r given by at, in the layout of SumX, with the rows split between
+// the workers.
+func sumRows(dv []uint64, r image.Rectangle, at func(x, y int) uint16, workers int) {
+	w := r.Dx()
+	parallel(r.Dy(), workers, func(lo, hi int) {
+		for y := lo; y < hi; y++ {
+			for x, v := 0, uint64(0); x < w; x++ {
+				v += uint64(at(x+r.Min.X, y+r.Min.Y))
+				dv[x+y*w] = v
+			}
+		}
+	})
+}
+
+// sumColumns fills dv with the sums along the columns of the densities
+// over r given by at, in the transposed layout of SumY, with the
+// columns split between the workers.
+func sumColumns(dv []uint64, r image.Rectangle, at func(x, y int) uint16, workers int) {
+	h := r.Dy()
+	parallel(r.Dx(), workers, func(lo, hi int) {
+		for x := lo; x < hi; x++ {
+			for y, v := 0, uint64(0); y < h; y++ {
+				v += uint64(at(x+r.Min.X, y+r.Min.Y))
+				dv[x*h+y] = v
+			}
+		}
+	})
+}
+
+// sumArea fills dv with the double sums of the densities over r given
+// by at, in the layout of DSum. This is a prefix scan in two passes:
+// the rows are summed first, split between the workers, and then the
+// columns.
+func sumArea(dv []uint64, r image.Rectangle, at func(x, y int) uint16, workers int) {
+	sumRows(dv, r, at, workers)
+	addRows(dv, r.Dx(), r.Dy(), workers)
+}
+
+// addRows adds every row of the w by h values in dv to the row below
+// it, from the top down, with every worker adding up a band of columns.
+func addRows(dv []uint64, w, h, workers int) {
+	parallel(w, workers, func(lo, hi int) {
+		for y := 1; y < h; y++ {
+			for x := lo; x < hi; x++ {
+				dv[x+y*w] += dv[x+(y-1)*w]
+			}
+		}
+	})
+}
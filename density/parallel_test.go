@@ -0,0 +1,70 @@
+package density
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestParallel(t *testing.T) {
+	for _, i := range testImages() {
+		for k, m := range testModels() {
+			// The reference is built on one goroutine, through At.
+			p := plain{i}
+			cbs := CubeSumFrom(p, m, 2)
+			cbs.AddFrame(flipped{p}, m)
+			want := []interface{}{
+				MapFrom(p, m),
+				SumXFrom(p, m),
+				SumYFrom(p, m),
+				SumFrom(p, m),
+				DSumFrom(p, m),
+				MomentSumFrom(p, m),
+				SecondMomentSumFrom(p, m),
+				cbs,
+			}
+			// Zero uses GOMAXPROCS; 64 is more than there are
+			// rows or columns.
+			for _, n := range []int{0, 2, 3, 7, 64} {
+				cbs := CubeSumFromN(i, m, 2, n)
+				cbs.AddFrameN(flipped{i}, m, n)
+				got := []interface{}{
+					MapFromN(i, m, n),
+					SumXFromN(i, m, n),
+					SumYFromN(i, m, n),
+					SumFromN(i, m, n),
+					DSumFromN(i, m, n),
+					MomentSumFromN(i, m, n),
+					SecondMomentSumFromN(i, m, n),
+					cbs,
+				}
+				for j := range got {
+					if !reflect.DeepEqual(got[j], want[j]) {
+						t.Errorf("%T, model %d, n=%d: %T differs from the sequential one", i, k, n, got[j])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestParallelSplit(t *testing.T) {
+	for _, size := range []int{0, 1, 5, 17} {
+		for _, workers := range []int{-1, 0, 1, 2, 3, 16, 100} {
+			seen := make([]int, size)
+			ch := make(chan image.Point, size+1)
+			parallel(size, workers, func(lo, hi int) { ch <- image.Pt(lo, hi) })
+			close(ch)
+			for p := range ch {
+				for k := p.X; k < p.Y; k++ {
+					seen[k]++
+				}
+			}
+			for k, n := range seen {
+				if n != 1 {
+					t.Errorf("size %d, %d workers: %d covered %d times", size, workers, k, n)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package density
+
+import (
+	"math"
+	"sync"
+)
+
+// Perceptual models for density functions. The luma models take the
+// weighted sum of the gamma-encoded channels, according to Rec. 709
+// (as used by sRGB) and Rec. 601 (as used by JPEG). The luminance
+// model linearises the sRGB channels first, giving the relative
+// luminance Y, while the lightness model maps that to CIE L*, which
+// is close to the perceived brightness. As with the default models,
+// the negative counterparts give the highest density to black.
+var (
+	Luma709Density      Model = rgbaFunc(luma709Density)
+	Luma601Density      Model = rgbaFunc(luma601Density)
+	LuminanceDensity    Model = rgbaFunc(luminanceDensity)
+	LightnessDensity    Model = rgbaFunc(lightnessDensity)
+	NegLuma709Density   Model = rgbaFunc(negate(luma709Density))
+	NegLuma601Density   Model = rgbaFunc(negate(luma601Density))
+	NegLuminanceDensity Model = rgbaFunc(negate(luminanceDensity))
+	NegLightnessDensity Model = rgbaFunc(negate(lightnessDensity))
+)
+
+var (
+	// linear holds the linearised value of every sRGB channel value,
+	// from 0 to 1. It is filled in on first use.
+	linear     [0x10000]float32
+	linearOnce sync.Once
+)
+
+// toLinear gives the linear value of the sRGB encoded channel value v.
+func toLinear(v uint32) float64 {
+	linearOnce.Do(func() {
+		for i := range linear {
+			c := float64(i) / 0xFFFF
+			if c <= 0.04045 {
+				linear[i] = float32(c / 12.92)
+			} else {
+				linear[i] = float32(math.Pow((c+0.055)/1.055, 2.4))
+			}
+		}
+	})
+	return float64(linear[v])
+}
+
+// luminance gives the relative luminance of the colour with channels
+// r, g and b, from 0 to 1.
+func luminance(r, g, b uint32) float64 {
+	return 0.2126*toLinear(r) + 0.7152*toLinear(g) + 0.0722*toLinear(b)
+}
+
+// lightness gives the CIE L* of the colour with channels r, g and b,
+// scaled from 0 to 1.
+func lightness(r, g, b uint32) float64 {
+	y := luminance(r, g, b)
+	if y > 216.0/24389 {
+		return 1.16*math.Cbrt(y) - 0.16
+	}
+	return y * 24389 / 2700
+}
+
+// unit converts v, from 0 to 1, to a density.
+func unit(v float64) uint16 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 1:
+		return 0xFFFF
+	}
+	return uint16(v*0xFFFF + 0.5)
+}
+
+// negate returns a density function that gives the complement of f.
+func negate(f func(r, g, b, a uint32) uint16) func(r, g, b, a uint32) uint16 {
+	return func(r, g, b, a uint32) uint16 { return 0xFFFF - f(r, g, b, a) }
+}
+
+func luma709Density(r, g, b, _ uint32) (d uint16) {
+	d = uint16((2126*r + 7152*g + 722*b + 5000) / 10000)
+	return
+}
+
+func luma601Density(r, g, b, _ uint32) (d uint16) {
+	d = uint16((2990*r + 5870*g + 1140*b + 5000) / 10000)
+	return
+}
+
+func luminanceDensity(r, g, b, _ uint32) (d uint16) {
+	return unit(luminance(r, g, b))
+}
+
+func lightnessDensity(r, g, b, _ uint32) (d uint16) {
+	return unit(lightness(r, g, b))
+}
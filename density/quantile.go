@@ -0,0 +1,107 @@
+package density
+
+import (
+	"image"
+	"sort"
+)
+
+// quantile is the search behind all the FindC and FindQ methods. It
+// returns the t from lo up to and including hi for which mass(t), the
+// mass from lo up to but not including t, is closest to fraction of
+// mass(hi). The fraction is clipped to [0, 1], and mass must not
+// decrease with t. Of two equally close t, the lowest wins, so that
+// where the mass does not change, as over empty columns, the line
+// stays on the side of lo.
+func quantile(lo, hi int, fraction float64, mass func(t int) uint64) int {
+	if hi <= lo {
+		return lo
+	}
+	switch {
+	case fraction < 0:
+		fraction = 0
+	case fraction > 1:
+		fraction = 1
+	}
+	target := fraction * float64(mass(hi))
+	first := func(v float64) int {
+		return lo + sort.Search(hi-lo, func(k int) bool { return float64(mass(lo+k)) >= v })
+	}
+	t := first(target)
+	if t > lo {
+		if below := float64(mass(t - 1)); target-below <= float64(mass(t))-target {
+			t = first(below)
+		}
+	}
+	return t
+}
+
+// Given a Rectangle, finds x closest to the line that splits the mass
+// of the area bound by these coordinates into fraction of it on the
+// left, and the rest on the right. FindCx is the same for a fraction
+// of 0.5; this allows for uneven and k-way splits.
+func (ds *DSum) FindQx(r image.Rectangle, fraction float64) int {
+	return areaFindQx(ds, r, fraction)
+}
+
+// Given a Rectangle, finds y closest to the line that splits the mass
+// of the area bound by these coordinates into fraction of it above,
+// and the rest below.
+func (ds *DSum) FindQy(r image.Rectangle, fraction float64) int {
+	return areaFindQy(ds, r, fraction)
+}
+
+// zRange clips zmin and zmax to the frames in the cube.
+func (cbs *CubeSum) zRange(zmin, zmax int) (int, int) {
+	if zmin < 0 {
+		zmin = 0
+	}
+	if zmax > cbs.LenZ {
+		zmax = cbs.LenZ
+	}
+	return zmin, zmax
+}
+
+// findQx3, findQy3 and findQz3 are FindQx, FindQy and FindQz for any
+// cube with box sums given by volumeSum, with r and zmin-zmax inside
+// its bounds.
+func findQx3(r image.Rectangle, zmin, zmax int, fraction float64, volumeSum func(image.Rectangle, int, int) uint64) int {
+	return quantile(r.Min.X, r.Max.X, fraction, func(x int) uint64 {
+		return volumeSum(image.Rect(r.Min.X, r.Min.Y, x, r.Max.Y), zmin, zmax)
+	})
+}
+
+func findQy3(r image.Rectangle, zmin, zmax int, fraction float64, volumeSum func(image.Rectangle, int, int) uint64) int {
+	return quantile(r.Min.Y, r.Max.Y, fraction, func(y int) uint64 {
+		return volumeSum(image.Rect(r.Min.X, r.Min.Y, r.Max.X, y), zmin, zmax)
+	})
+}
+
+func findQz3(r image.Rectangle, zmin, zmax int, fraction float64, volumeSum func(image.Rectangle, int, int) uint64) int {
+	return quantile(zmin, zmax, fraction, func(z int) uint64 {
+		return volumeSum(r, zmin, z)
+	})
+}
+
+// Given a Rectangle and zmin/zmax, finds x closest to the plane that
+// splits the mass of the cube bound by these coordinates into fraction
+// of it on the left, and the rest on the right.
+func (cbs *CubeSum) FindQx(r image.Rectangle, zmin, zmax int, fraction float64) int {
+	zmin, zmax = cbs.zRange(zmin, zmax)
+	return findQx3(cbs.Rect.Intersect(r), zmin, zmax, fraction, cbs.VolumeSum)
+}
+
+// Given a Rectangle and zmin/zmax, finds y closest to the plane that
+// splits the mass of the cube bound by these coordinates into fraction
+// of it above, and the rest below.
+func (cbs *CubeSum) FindQy(r image.Rectangle, zmin, zmax int, fraction float64) int {
+	zmin, zmax = cbs.zRange(zmin, zmax)
+	return findQy3(cbs.Rect.Intersect(r), zmin, zmax, fraction, cbs.VolumeSum)
+}
+
+// Given a Rectangle and zmin/zmax, finds z closest to the plane that
+// splits the mass of the cube bound by these coordinates into fraction
+// of it in the frames before, and the rest in the frames after.
+func (cbs *CubeSum) FindQz(r image.Rectangle, zmin, zmax int, fraction float64) int {
+	zmin, zmax = cbs.zRange(zmin, zmax)
+	return findQz3(cbs.Rect.Intersect(r), zmin, zmax, fraction, cbs.VolumeSum)
+}
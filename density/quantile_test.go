@@ -0,0 +1,192 @@
+package density
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// quantileImage returns a gray image with random densities, but with
+// empty and full columns and rows, and an empty column at its right.
+func quantileImage() *image.Gray16 {
+	rnd := rand.New(rand.NewSource(1))
+	r := image.Rect(-3, 5, 26, 22)
+	img := image.NewGray16(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			var v uint16
+			switch {
+			case x == -2, x == 0, x >= 9 && x < 12, x == r.Max.X-1, y == 8:
+			case x == 5, y >= 15 && y < 17:
+				v = 0xFFFF
+			default:
+				v = uint16(rnd.Intn(0x10000))
+			}
+			img.SetGray16(x, y, color.Gray16{v})
+		}
+	}
+	return img
+}
+
+// bruteQ scans every t from lo up to and including hi for the one with
+// mass(t) closest to fraction of mass(hi), the lowest of equally close
+// ones.
+func bruteQ(lo, hi int, fraction float64, mass func(t int) uint64) int {
+	fraction = math.Min(math.Max(fraction, 0), 1)
+	target := fraction * float64(mass(hi))
+	best := lo
+	for t := lo; t <= hi; t++ {
+		if math.Abs(float64(mass(t))-target) < math.Abs(float64(mass(best))-target) {
+			best = t
+		}
+	}
+	return best
+}
+
+// pixelSum sums density over r, one pixel at a time.
+func pixelSum(r image.Rectangle, density func(x, y int) uint64) (sum uint64) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sum += density(x, y)
+		}
+	}
+	return
+}
+
+var testFractions = []float64{-1, 0, 0.1, 1.0 / 3, 0.5, 0.75, 0.9, 1, 2}
+
+func TestFindQ(t *testing.T) {
+	img := quantileImage()
+	b := img.Bounds()
+	gray := func(x, y int) uint64 { return uint64(img.Gray16At(x, y).Y) }
+	neg := func(x, y int) uint64 { return 0xFFFF - gray(x, y) }
+	clip := image.Rect(1, 7, 20, 18)
+	tests := []struct {
+		a       AreaSummer
+		density func(x, y int) uint64
+	}{
+		{DSumFrom(img, AvgDensity), gray},
+		{FenwickSumFrom(img, AvgDensity), gray},
+		{MapFrom(img, AvgDensity), gray},
+		{SumFrom(img, AvgDensity), gray},
+		{Complement{DSumFrom(img, AvgDensity)}, neg},
+		{Clipped{DSumFrom(img, AvgDensity), clip}, gray},
+		{Clipped{Complement{FenwickSumFrom(img, AvgDensity)}, clip}, neg},
+	}
+	rnd := rand.New(rand.NewSource(1))
+	rects := testRects(b)
+	for i := 0; i < 20; i++ {
+		p := image.Pt(b.Min.X+rnd.Intn(b.Dx()), b.Min.Y+rnd.Intn(b.Dy()))
+		rects = append(rects, image.Rectangle{p, p.Add(image.Pt(rnd.Intn(b.Dx()), rnd.Intn(b.Dy())))})
+	}
+	for _, test := range tests {
+		for _, r := range rects {
+			q := r.Intersect(test.a.Bounds())
+			for _, f := range testFractions {
+				want := bruteQ(q.Min.X, q.Max.X, f, func(x int) uint64 {
+					return pixelSum(image.Rect(q.Min.X, q.Min.Y, x, q.Max.Y), test.density)
+				})
+				if got := test.a.FindQx(r, f); got != want {
+					t.Errorf("%T, %v, %v: FindQx = %d, want %d", test.a, r, f, got, want)
+				}
+				want = bruteQ(q.Min.Y, q.Max.Y, f, func(y int) uint64 {
+					return pixelSum(image.Rect(q.Min.X, q.Min.Y, q.Max.X, y), test.density)
+				})
+				if got := test.a.FindQy(r, f); got != want {
+					t.Errorf("%T, %v, %v: FindQy = %d, want %d", test.a, r, f, got, want)
+				}
+			}
+			if got, want := test.a.FindCx(r), test.a.FindQx(r, 0.5); got != want {
+				t.Errorf("%T, %v: FindCx = %d, want %d", test.a, r, got, want)
+			}
+			if got, want := test.a.FindCy(r), test.a.FindQy(r, 0.5); got != want {
+				t.Errorf("%T, %v: FindCy = %d, want %d", test.a, r, got, want)
+			}
+		}
+	}
+}
+
+func TestCubeFindQ(t *testing.T) {
+	img := quantileImage()
+	b := img.Bounds()
+	cbs := CubeSumFrom(img, AvgDensity, 3)
+	cbs.AddFrame(flipped{img}, AvgDensity)
+	cbs.AddFrame(image.NewGray16(b), AvgDensity)
+	density := func(x, y, z int) uint64 {
+		switch z {
+		case 0:
+			return uint64(img.Gray16At(x, y).Y)
+		case 1:
+			return uint64(img.Gray16At(x, b.Max.Y-1-(y-b.Min.Y)).Y)
+		}
+		return 0
+	}
+	boxSum := func(r image.Rectangle, zmin, zmax int, neg bool) (sum uint64) {
+		for z := zmin; z < zmax; z++ {
+			sum += pixelSum(r, func(x, y int) uint64 {
+				if neg {
+					return 0xFFFF - density(x, y, z)
+				}
+				return density(x, y, z)
+			})
+		}
+		return
+	}
+	type finder interface {
+		FindQx(r image.Rectangle, zmin, zmax int, fraction float64) int
+		FindQy(r image.Rectangle, zmin, zmax int, fraction float64) int
+		FindQz(r image.Rectangle, zmin, zmax int, fraction float64) int
+		FindCx(r image.Rectangle, zmin, zmax int) int
+		FindCy(r image.Rectangle, zmin, zmax int) int
+		FindCz(r image.Rectangle, zmin, zmax int) int
+	}
+	for _, neg := range []bool{false, true} {
+		var c finder = cbs
+		if neg {
+			c = CubeComplement{cbs}
+		}
+		for _, r := range testRects(b) {
+			q := r.Intersect(b)
+			for _, z := range [][2]int{{0, 1}, {0, 2}, {1, 3}, {0, 3}, {-1, 5}} {
+				zmin, zmax := z[0], z[1]
+				if zmin < 0 {
+					zmin = 0
+				}
+				if zmax > 3 {
+					zmax = 3
+				}
+				for _, f := range testFractions {
+					want := bruteQ(q.Min.X, q.Max.X, f, func(x int) uint64 {
+						return boxSum(image.Rect(q.Min.X, q.Min.Y, x, q.Max.Y), zmin, zmax, neg)
+					})
+					if got := c.FindQx(r, z[0], z[1], f); got != want {
+						t.Errorf("%T, %v, %v, %v: FindQx = %d, want %d", c, r, z, f, got, want)
+					}
+					want = bruteQ(q.Min.Y, q.Max.Y, f, func(y int) uint64 {
+						return boxSum(image.Rect(q.Min.X, q.Min.Y, q.Max.X, y), zmin, zmax, neg)
+					})
+					if got := c.FindQy(r, z[0], z[1], f); got != want {
+						t.Errorf("%T, %v, %v, %v: FindQy = %d, want %d", c, r, z, f, got, want)
+					}
+					want = bruteQ(zmin, zmax, f, func(z int) uint64 {
+						return boxSum(q, zmin, z, neg)
+					})
+					if got := c.FindQz(r, z[0], z[1], f); got != want {
+						t.Errorf("%T, %v, %v, %v: FindQz = %d, want %d", c, r, z, f, got, want)
+					}
+				}
+				if got, want := c.FindCx(r, z[0], z[1]), c.FindQx(r, z[0], z[1], 0.5); got != want {
+					t.Errorf("%T, %v, %v: FindCx = %d, want %d", c, r, z, got, want)
+				}
+				if got, want := c.FindCy(r, z[0], z[1]), c.FindQy(r, z[0], z[1], 0.5); got != want {
+					t.Errorf("%T, %v, %v: FindCy = %d, want %d", c, r, z, got, want)
+				}
+				if got, want := c.FindCz(r, z[0], z[1]), c.FindQz(r, z[0], z[1], 0.5); got != want {
+					t.Errorf("%T, %v, %v: FindCz = %d, want %d", c, r, z, got, want)
+				}
+			}
+		}
+	}
+}
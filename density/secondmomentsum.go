@@ -0,0 +1,164 @@
+package density
+
+import (
+	"image"
+	"math"
+)
+
+// SecondMomentSum is a MomentSum that also holds the double sums of
+// the densities weighed by x², y² and xy, and of the squared densities.
+// With those, the variance of the densities and the spread of the mass
+// of any rectangle take constant time to find.
+//
+// The sums wrap around on large maps, but the results for a rectangle
+// stay exact as long as its own sums fit in 64 bits, which holds for
+// rectangles up to 4096 by 4096 pixels.
+type SecondMomentSum struct {
+	MomentSum
+	// WXX, WYY and WXY hold the double sums of the densities weighed
+	// by x², y² and xy, and VV those of the squared densities, all in
+	// the same layout as Values. Like WX and WY, these are not
+	// bounds-corrected.
+	WXX, WYY, WXY, VV []uint64
+}
+
+func (d *SecondMomentSum) Copy(s *SecondMomentSum) {
+	d.MomentSum.Copy(&s.MomentSum)
+	for _, t := range []struct{ dst, src *[]uint64 }{
+		{&d.WXX, &s.WXX}, {&d.WYY, &s.WYY}, {&d.WXY, &s.WXY}, {&d.VV, &s.VV},
+	} {
+		*t.dst = make([]uint64, len(*t.src), cap(*t.src))
+		copy(*t.dst, *t.src)
+	}
+}
+
+// Like DSum.Set, this has to update the entire area from (x,y) to the
+// bottom right, but does so for all the moments. It takes time
+// proportional to the area of the map.
+func (d *SecondMomentSum) Set(x, y int, v uint16) {
+	if !(image.Point{x, y}.In(d.Rect)) {
+		return
+	}
+	p := image.Rect(x, y, x+1, y+1)
+	old := d.AreaMass(p)
+	dv := uint64(v) - old
+	dvv := uint64(v)*uint64(v) - old*old
+	px, py := uint64(x-d.Rect.Min.X), uint64(y-d.Rect.Min.Y)
+	for j := y; j < d.Rect.Max.Y; j++ {
+		for i := x; i < d.Rect.Max.X; i++ {
+			k := d.DVOffSet(i, j)
+			d.Values[k] += dv
+			d.WX[k] += dv * px
+			d.WY[k] += dv * py
+			d.WXX[k] += dv * px * px
+			d.WYY[k] += dv * py * py
+			d.WXY[k] += dv * px * py
+			d.VV[k] += dvv
+		}
+	}
+}
+
+// AreaVariance returns the variance of the densities of the pixels in
+// the rectangle r, from r.Min up to but not including r.Max. It is
+// zero where the densities are uniform.
+func (d *SecondMomentSum) AreaVariance(r image.Rectangle) float64 {
+	r = r.Intersect(d.Rect)
+	if r.Empty() {
+		return 0
+	}
+	n := float64(r.Dx() * r.Dy())
+	mean := float64(d.AreaMass(r)) / n
+	return math.Max(float64(d.area(d.VV, r))/n-mean*mean, 0)
+}
+
+// AreaCovariance returns the variances and the covariance of the
+// positions of the mass in the rectangle r, around its centre of mass.
+// Multiplied by the mass, yy and xx are the moments of inertia of r
+// around the horizontal and vertical axis through its centre of mass,
+// and -xy its product of inertia, which together make up its inertia
+// tensor. A rectangle without mass gives zero for all three.
+func (d *SecondMomentSum) AreaCovariance(r image.Rectangle) (xx, yy, xy float64) {
+	r = r.Intersect(d.Rect)
+	mass := d.AreaMass(r)
+	if mass == 0 {
+		return
+	}
+	// The moments around r.Min are exact in modular arithmetic, and
+	// a lot smaller than those around the origin of the map, which
+	// keeps the subtractions below that move them to the centre of
+	// mass precise.
+	ox, oy := uint64(r.Min.X-d.Rect.Min.X), uint64(r.Min.Y-d.Rect.Min.Y)
+	wx, wy := d.area(d.WX, r), d.area(d.WY, r)
+	wxx := d.area(d.WXX, r) - 2*ox*wx + ox*ox*mass
+	wyy := d.area(d.WYY, r) - 2*oy*wy + oy*oy*mass
+	wxy := d.area(d.WXY, r) - ox*wy - oy*wx + ox*oy*mass
+	wx -= ox * mass
+	wy -= oy * mass
+
+	m := float64(mass)
+	cx, cy := float64(wx)/m, float64(wy)/m
+	xx = math.Max(float64(wxx)/m-cx*cx, 0)
+	yy = math.Max(float64(wyy)/m-cy*cy, 0)
+	xy = float64(wxy)/m - cx*cy
+	return
+}
+
+// AreaPrincipalAxis returns the principal axis of the mass in the
+// rectangle r, as the angle theta of its major axis in radians from
+// the x-axis towards the y-axis, between -π/2 and π/2. The variances
+// of the positions along the major and the minor axis are returned as
+// well; the closer these are, the less defined the axis is.
+func (d *SecondMomentSum) AreaPrincipalAxis(r image.Rectangle) (theta, major, minor float64) {
+	xx, yy, xy := d.AreaCovariance(r)
+	theta = math.Atan2(2*xy, xx-yy) / 2
+	mean, dev := (xx+yy)/2, math.Hypot((xx-yy)/2, xy)
+	return theta, mean + dev, math.Max(mean-dev, 0)
+}
+
+func NewSecondMomentSum(r image.Rectangle) *SecondMomentSum {
+	w, h := r.Dx(), r.Dy()
+	return &SecondMomentSum{
+		MomentSum: *NewMomentSum(r),
+		WXX:       make([]uint64, w*h),
+		WYY:       make([]uint64, w*h),
+		WXY:       make([]uint64, w*h),
+		VV:        make([]uint64, w*h),
+	}
+}
+
+func SecondMomentSumFrom(i image.Image, d Model) *SecondMomentSum {
+	return SecondMomentSumFromN(i, d, 1)
+}
+
+// SecondMomentSumFromN is like SecondMomentSumFrom, but sums the rows
+// and then the columns of i on n goroutines.
+func SecondMomentSumFromN(i image.Image, d Model, n int) *SecondMomentSum {
+	r := i.Bounds()
+	at := densities(i, d)
+	w, h := r.Dx(), r.Dy()
+	ms := NewSecondMomentSum(r)
+	parallel(h, n, func(lo, hi int) {
+		for y := lo; y < hi; y++ {
+			py := uint64(y)
+			var v, vx, vy, vxx, vyy, vxy, vv uint64
+			for x := 0; x < w; x++ {
+				dv := uint64(at(x+r.Min.X, y+r.Min.Y))
+				px := uint64(x)
+				v += dv
+				vx += dv * px
+				vy += dv * py
+				vxx += dv * px * px
+				vyy += dv * py * py
+				vxy += dv * px * py
+				vv += dv * dv
+				k := x + y*w
+				ms.Values[k], ms.WX[k], ms.WY[k] = v, vx, vy
+				ms.WXX[k], ms.WYY[k], ms.WXY[k], ms.VV[k] = vxx, vyy, vxy, vv
+			}
+		}
+	})
+	for _, t := range [][]uint64{ms.Values, ms.WX, ms.WY, ms.WXX, ms.WYY, ms.WXY, ms.VV} {
+		addRows(t, w, h, n)
+	}
+	return ms
+}
@@ -0,0 +1,154 @@
+package density
+
+import (
+	"image"
+	"math"
+)
+
+// The subpixel sums of a MomentSum take the density of a pixel to be
+// spread evenly over it, with pixel (x, y) covering the unit square
+// from (x, y) up to (x+1, y+1). A rectangle with fractional corners
+// then covers part of the pixels along its edges, and gets that part
+// of their mass. Like AreaCM, this puts the centre of a pixel at
+// (x+0.5, y+0.5), half a pixel off from Map.CM, which puts it at
+// (x, y).
+//
+// The corners can be given as float64, or in fixed point with prec
+// fractional bits, which keeps the sums exact.
+
+// A span is the part of an interval along one axis that covers the
+// pixels from lo up to but not including hi. All of these are covered
+// by the same fraction w, and if only one is covered partly, c is the
+// centre of the part covered.
+type span struct {
+	lo, hi int
+	w, c   float64
+}
+
+// spans splits the interval from a up to b into the partly covered
+// pixels at both ends, and the fully covered ones in between.
+func spans(a, b float64) []span {
+	if b <= a {
+		return nil
+	}
+	pa, pb := math.Floor(a), math.Floor(b)
+	if pa == pb {
+		return []span{{int(pa), int(pa) + 1, b - a, (a + b) / 2}}
+	}
+	s := []span{{int(pa), int(pa) + 1, pa + 1 - a, (a + pa + 1) / 2}}
+	if pb > pa+1 {
+		s = append(s, span{lo: int(pa) + 1, hi: int(pb), w: 1})
+	}
+	if b > pb {
+		s = append(s, span{int(pb), int(pb) + 1, b - pb, (pb + b) / 2})
+	}
+	return s
+}
+
+// moment gives the first moment along the axis of the mass m in the
+// pixels of s, with wm their moment as stored in a MomentSum, which
+// has its origin at min.
+func (s span) moment(m, wm float64, min int) float64 {
+	if s.w == 1 {
+		return wm + (float64(min)+0.5)*m
+	}
+	return m * s.c
+}
+
+// SubMass returns the mass of the rectangle from (x0, y0) up to
+// (x1, y1), including the parts of the pixels it covers partly.
+func (d *MomentSum) SubMass(x0, y0, x1, y1 float64) (mass float64) {
+	for _, sy := range spans(y0, y1) {
+		for _, sx := range spans(x0, x1) {
+			mass += sx.w * sy.w * float64(d.AreaMass(image.Rect(sx.lo, sy.lo, sx.hi, sy.hi)))
+		}
+	}
+	return
+}
+
+// SubCM returns the centre of mass of the rectangle from (x0, y0) up
+// to (x1, y1), including the parts of the pixels it covers partly. If
+// the rectangle has no mass, its centre is returned instead.
+func (d *MomentSum) SubCM(x0, y0, x1, y1 float64) (x, y float64) {
+	var mass, wx, wy float64
+	for _, sy := range spans(y0, y1) {
+		for _, sx := range spans(x0, x1) {
+			m, mx, my := d.AreaMoments(image.Rect(sx.lo, sy.lo, sx.hi, sy.hi))
+			if m == 0 {
+				continue
+			}
+			w, fm := sx.w*sy.w, float64(m)
+			mass += w * fm
+			wx += w * sx.moment(fm, float64(mx), d.Rect.Min.X)
+			wy += w * sy.moment(fm, float64(my), d.Rect.Min.Y)
+		}
+	}
+	if mass == 0 {
+		return (x0 + x1) / 2, (y0 + y1) / 2
+	}
+	return wx / mass, wy / mass
+}
+
+// A fixedSpan is a span in fixed point, with w ranging up to one.
+type fixedSpan struct {
+	lo, hi int
+	w      uint64
+}
+
+// fixedSpans is spans for fixed point, with prec fractional bits.
+func fixedSpans(a, b int64, prec uint) []fixedSpan {
+	if b <= a {
+		return nil
+	}
+	one := int64(1) << prec
+	pa, pb := a>>prec, b>>prec
+	if pa == pb {
+		return []fixedSpan{{int(pa), int(pa) + 1, uint64(b - a)}}
+	}
+	s := []fixedSpan{{int(pa), int(pa) + 1, uint64((pa+1)<<prec - a)}}
+	if pb > pa+1 {
+		s = append(s, fixedSpan{int(pa) + 1, int(pb), uint64(one)})
+	}
+	if f := b & (one - 1); f != 0 {
+		s = append(s, fixedSpan{int(pb), int(pb) + 1, uint64(f)})
+	}
+	return s
+}
+
+// FixedSubMass is like SubMass, with the corners in fixed point with
+// prec fractional bits. It returns the mass in fixed point as well,
+// rounded down. The result is exact as long as the mass of the
+// rectangle shifted left by prec, and four times full density shifted
+// left by 2·prec, fit in 64 bits.
+func (d *MomentSum) FixedSubMass(x0, y0, x1, y1 int64, prec uint) (mass uint64) {
+	one := uint64(1) << prec
+	// The pixels in the corners are partly covered along both
+	// axes, and their mass has 2·prec fractional bits. It is
+	// summed apart, and rounded down only once.
+	var corners uint64
+	for _, sy := range fixedSpans(y0, y1, prec) {
+		for _, sx := range fixedSpans(x0, x1, prec) {
+			m := d.AreaMass(image.Rect(sx.lo, sy.lo, sx.hi, sy.hi))
+			switch {
+			case sx.w == one && sy.w == one:
+				mass += m << prec
+			case sx.w == one:
+				mass += sy.w * m
+			case sy.w == one:
+				mass += sx.w * m
+			default:
+				corners += sx.w * sy.w * m
+			}
+		}
+	}
+	return mass + corners>>prec
+}
+
+// FixedSubCM is like SubCM, with the corners and the centre of mass in
+// fixed point with prec fractional bits. The centre of mass is rounded
+// to the nearest fixed-point value.
+func (d *MomentSum) FixedSubCM(x0, y0, x1, y1 int64, prec uint) (x, y int64) {
+	one := float64(uint64(1) << prec)
+	fx, fy := d.SubCM(float64(x0)/one, float64(y0)/one, float64(x1)/one, float64(y1)/one)
+	return int64(math.Floor(fx*one + 0.5)), int64(math.Floor(fy*one + 0.5))
+}
@@ -0,0 +1,116 @@
+package density
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// subpixelImage returns a small image away from the origin, with
+// random densities and a row of empty pixels.
+func subpixelImage() *image.Gray16 {
+	rnd := rand.New(rand.NewSource(1))
+	i := image.NewGray16(image.Rect(2, 3, 9, 8))
+	for y := 3; y < 8; y++ {
+		for x := 2; x < 9; x++ {
+			if y != 5 {
+				i.SetGray16(x, y, color.Gray16{uint16(rnd.Intn(0x10000))})
+			}
+		}
+	}
+	return i
+}
+
+// bruteSub gives the mass and centre of mass of the rectangle from
+// (x0, y0) up to (x1, y1) over i, pixel by pixel, taking the density
+// of pixel (x, y) to be spread evenly over the unit square from (x, y)
+// up to (x+1, y+1).
+func bruteSub(i *image.Gray16, x0, y0, x1, y1 float64) (mass, x, y float64) {
+	r := i.Bounds()
+	var wx, wy float64
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			ax, bx := math.Max(x0, float64(px)), math.Min(x1, float64(px+1))
+			ay, by := math.Max(y0, float64(py)), math.Min(y1, float64(py+1))
+			if bx <= ax || by <= ay {
+				continue
+			}
+			m := (bx - ax) * (by - ay) * float64(i.Gray16At(px, py).Y)
+			mass += m
+			wx += m * (ax + bx) / 2
+			wy += m * (ay + by) / 2
+		}
+	}
+	if mass == 0 {
+		return 0, (x0 + x1) / 2, (y0 + y1) / 2
+	}
+	return mass, wx / mass, wy / mass
+}
+
+var subpixelTests = []struct {
+	name           string
+	x0, y0, x1, y1 float64
+}{
+	{"whole map", 2, 3, 9, 8},
+	{"whole pixels", 3, 4, 7, 7},
+	{"fractional corners", 2.25, 3.5, 8.75, 7.125},
+	{"fractional corners inside", 3.375, 4.5, 6.625, 6.25},
+	{"single pixel", 4, 4, 5, 5},
+	{"within a pixel", 4.25, 4.125, 4.75, 4.5},
+	{"within a column", 4.25, 3.5, 4.75, 7.5},
+	{"within a row", 2.5, 6.25, 8.5, 6.75},
+	{"empty row", 2.5, 5, 8.5, 6},
+	{"zero width", 4.5, 4, 4.5, 7},
+	{"zero height", 3, 6.5, 7, 6.5},
+	{"inverted", 6, 6, 4, 4},
+	{"clipped top left", 0.5, 1.25, 4.5, 5.5},
+	{"clipped bottom right", 6.25, 5.5, 11.5, 9.75},
+	{"covering the map", -1.5, -2.5, 12.25, 10.5},
+	{"outside", 10.5, 1, 12, 2.5},
+}
+
+func TestSubpixel(t *testing.T) {
+	i := subpixelImage()
+	ms := MomentSumFrom(i, ModelFunc(func(c color.Color) uint16 {
+		return color.Gray16Model.Convert(c).(color.Gray16).Y
+	}))
+	for _, test := range subpixelTests {
+		mass, x, y := bruteSub(i, test.x0, test.y0, test.x1, test.y1)
+		if got := ms.SubMass(test.x0, test.y0, test.x1, test.y1); math.Abs(got-mass) > 1e-9*math.Max(mass, 1) {
+			t.Errorf("%s: SubMass = %v, want %v", test.name, got, mass)
+		}
+		if gx, gy := ms.SubCM(test.x0, test.y0, test.x1, test.y1); math.Abs(gx-x) > 1e-9 || math.Abs(gy-y) > 1e-9 {
+			t.Errorf("%s: SubCM = (%v, %v), want (%v, %v)", test.name, gx, gy, x, y)
+		}
+
+		// The corners all have at most 3 fractional bits, so they
+		// are exact in fixed point, as is the mass with the
+		// areas taken to 2·prec bits.
+		const prec = 4
+		one := float64(uint64(1) << prec)
+		fx0, fy0 := int64(test.x0*one), int64(test.y0*one)
+		fx1, fy1 := int64(test.x1*one), int64(test.y1*one)
+		if got, want := ms.FixedSubMass(fx0, fy0, fx1, fy1, prec), uint64(math.Floor(mass*one)); got != want {
+			t.Errorf("%s: FixedSubMass = %d, want %d", test.name, got, want)
+		}
+		gx, gy := ms.FixedSubCM(fx0, fy0, fx1, fy1, prec)
+		if math.Abs(float64(gx)-x*one) > 0.5+1e-6 || math.Abs(float64(gy)-y*one) > 0.5+1e-6 {
+			t.Errorf("%s: FixedSubCM = (%d, %d), want (%v, %v)", test.name, gx, gy, x*one, y*one)
+		}
+
+		// For whole pixels, AreaMass and AreaCM give the same.
+		if test.x0 != math.Floor(test.x0) || test.y0 != math.Floor(test.y0) ||
+			test.x1 != math.Floor(test.x1) || test.y1 != math.Floor(test.y1) {
+			continue
+		}
+		r := image.Rectangle{image.Pt(int(test.x0), int(test.y0)), image.Pt(int(test.x1), int(test.y1))}
+		if got := ms.AreaMass(r); float64(got) != mass {
+			t.Errorf("%s: AreaMass = %d, want %v", test.name, got, mass)
+		}
+		if gx, gy := ms.AreaCM(r); math.Abs(gx-x) > 1e-9 || math.Abs(gy-y) > 1e-9 {
+			t.Errorf("%s: AreaCM = (%v, %v), want (%v, %v)", test.name, gx, gy, x, y)
+		}
+	}
+}
@@ -30,23 +30,67 @@ func (d *Sum) ColorModel() color.Model {
 	return color.Gray16Model
 }
 
+// Set sets the density at (x, y) to v in both X and Y, which takes
+// time proportional to the width plus the height of d.
+func (d *Sum) Set(x, y int, v uint16) {
+	d.X.Set(x, y, v)
+	d.Y.Set(x, y, v)
+}
+
+// AreaSum returns the sum of the densities in r, from r.Min up to but
+// not including r.Max, using whichever of X and Y takes the fewest
+// lookups.
+func (d *Sum) AreaSum(r image.Rectangle) uint64 {
+	r = r.Intersect(d.Bounds())
+	if r.Dy() < r.Dx() {
+		return d.X.AreaSum(r)
+	}
+	return d.Y.AreaSum(r)
+}
+
+// FindCx returns the x closest to the vertical line that divides the
+// mass of r in half, with r clipped to the bounds of d.
+func (d *Sum) FindCx(r image.Rectangle) int {
+	return d.FindQx(r, 0.5)
+}
+
+// FindCy returns the y closest to the horizontal line that divides
+// the mass of r in half, with r clipped to the bounds of d.
+func (d *Sum) FindCy(r image.Rectangle) int {
+	return d.FindQy(r, 0.5)
+}
+
+// FindQx returns the x closest to the vertical line that puts fraction
+// of the mass of r on its left, with r clipped to the bounds of d.
+func (d *Sum) FindQx(r image.Rectangle, fraction float64) int {
+	return areaFindQx(d, r, fraction)
+}
+
+// FindQy returns the y closest to the horizontal line that puts
+// fraction of the mass of r above it, with r clipped to the bounds
+// of d.
+func (d *Sum) FindQy(r image.Rectangle, fraction float64) int {
+	return areaFindQy(d, r, fraction)
+}
+
+func NewSum(r image.Rectangle) *Sum {
+	return &Sum{X: *NewSumX(r), Y: *NewSumY(r)}
+}
+
 func SumFrom(i image.Image, d Model) *Sum {
+	return SumFromN(i, d, 1)
+}
+
+// SumFromN is like SumFrom, but sums the rows and columns of i on n
+// goroutines.
+func SumFromN(i image.Image, d Model, n int) *Sum {
 	r := i.Bounds()
+	at := densities(i, d)
 	w, h := r.Dx(), r.Dy()
 	xdv := make([]uint64, w*h)
-	for y := 0; y < h; y++ {
-		for x, v := 0, uint64(0); x < w; x++ {
-			v += uint64(d.Convert(i.At(x+r.Min.X, y+r.Min.Y)))
-			xdv[x+y*w] = v
-		}
-	}
+	sumRows(xdv, r, at, n)
 	ydv := make([]uint64, w*h)
-	for x := 0; x < w; x++ {
-		for y, v := 0, uint64(0); y < h; y++ {
-			v += uint64(d.Convert(i.At(x+r.Min.X, y+r.Min.Y)))
-			ydv[x*h+y] = v
-		}
-	}
+	sumColumns(ydv, r, at, n)
 	return &Sum{
 		X: SumX{Values: xdv, Stride: w, Rect: r},
 		Y: SumY{Values: ydv, Stride: h, Rect: r},
@@ -47,6 +47,8 @@ func (d *SumX) ValueAt(x, y int) (v uint64) {
 	return
 }
 
+// Set sets the density at (x, y) to v. This updates the sums of the
+// rest of the row, which takes time proportional to the width of d.
 func (d *SumX) Set(x, y int, v uint16) {
 	if !(image.Point{x, y}.In(d.Rect)) {
 		return
@@ -66,6 +68,41 @@ func (d *SumX) Set(x, y int, v uint16) {
 	}
 }
 
+// AreaSum returns the sum of the densities in r, from r.Min up to but
+// not including r.Max, looking up two sums for every row.
+func (d *SumX) AreaSum(r image.Rectangle) (sum uint64) {
+	r = r.Intersect(d.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sum += d.ValueAt(r.Max.X-1, y) - d.ValueAt(r.Min.X-1, y)
+	}
+	return
+}
+
+// FindCx returns the x closest to the vertical line that divides the
+// mass of r in half, with r clipped to the bounds of d.
+func (d *SumX) FindCx(r image.Rectangle) int {
+	return d.FindQx(r, 0.5)
+}
+
+// FindCy returns the y closest to the horizontal line that divides
+// the mass of r in half, with r clipped to the bounds of d.
+func (d *SumX) FindCy(r image.Rectangle) int {
+	return d.FindQy(r, 0.5)
+}
+
+// FindQx returns the x closest to the vertical line that puts fraction
+// of the mass of r on its left, with r clipped to the bounds of d.
+func (d *SumX) FindQx(r image.Rectangle, fraction float64) int {
+	return areaFindQx(d, r, fraction)
+}
+
+// FindQy returns the y closest to the horizontal line that puts
+// fraction of the mass of r above it, with r clipped to the bounds
+// of d.
+func (d *SumX) FindQy(r image.Rectangle, fraction float64) int {
+	return areaFindQy(d, r, fraction)
+}
+
 func NewSumX(r image.Rectangle) *SumX {
 	w, h := r.Dx(), r.Dy()
 	dv := make([]uint64, w*h)
@@ -73,14 +110,14 @@ func NewSumX(r image.Rectangle) *SumX {
 }
 
 func SumXFrom(i image.Image, d Model) *SumX {
+	return SumXFromN(i, d, 1)
+}
+
+// SumXFromN is like SumXFrom, but sums the rows of i on n goroutines.
+func SumXFromN(i image.Image, d Model, n int) *SumX {
 	r := i.Bounds()
 	w, h := r.Dx(), r.Dy()
 	dv := make([]uint64, w*h)
-	for y := 0; y < h; y++ {
-		for x, v := 0, uint64(0); x < w; x++ {
-			v += uint64(d.Convert(i.At(x+r.Min.X, y+r.Min.Y)))
-			dv[x+y*w] = v
-		}
-	}
+	sumRows(dv, r, densities(i, d), n)
 	return &SumX{Values: dv, Stride: w, Rect: r}
 }
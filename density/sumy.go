@@ -49,6 +49,9 @@ func (d *SumY) ValueAt(x, y int) (v uint64) {
 	return
 }
 
+// Set sets the density at (x, y) to v. This updates the sums of the
+// rest of the column, which takes time proportional to the height of
+// d.
 func (d *SumY) Set(x, y int, v uint16) {
 	if !(image.Point{x, y}.In(d.Rect)) {
 		return
@@ -63,11 +66,46 @@ func (d *SumY) Set(x, y int, v uint16) {
 	}
 
 	// Now, update the column
-	for mi := i + d.Rect.Max.Y - x; i < mi; i++ {
+	for mi := i + d.Rect.Max.Y - y; i < mi; i++ {
 		d.Values[i] += dv
 	}
 }
 
+// AreaSum returns the sum of the densities in r, from r.Min up to but
+// not including r.Max, looking up two sums for every column.
+func (d *SumY) AreaSum(r image.Rectangle) (sum uint64) {
+	r = r.Intersect(d.Rect)
+	for x := r.Min.X; x < r.Max.X; x++ {
+		sum += d.ValueAt(x, r.Max.Y-1) - d.ValueAt(x, r.Min.Y-1)
+	}
+	return
+}
+
+// FindCx returns the x closest to the vertical line that divides the
+// mass of r in half, with r clipped to the bounds of d.
+func (d *SumY) FindCx(r image.Rectangle) int {
+	return d.FindQx(r, 0.5)
+}
+
+// FindCy returns the y closest to the horizontal line that divides
+// the mass of r in half, with r clipped to the bounds of d.
+func (d *SumY) FindCy(r image.Rectangle) int {
+	return d.FindQy(r, 0.5)
+}
+
+// FindQx returns the x closest to the vertical line that puts fraction
+// of the mass of r on its left, with r clipped to the bounds of d.
+func (d *SumY) FindQx(r image.Rectangle, fraction float64) int {
+	return areaFindQx(d, r, fraction)
+}
+
+// FindQy returns the y closest to the horizontal line that puts
+// fraction of the mass of r above it, with r clipped to the bounds
+// of d.
+func (d *SumY) FindQy(r image.Rectangle, fraction float64) int {
+	return areaFindQy(d, r, fraction)
+}
+
 func NewSumY(r image.Rectangle) *SumY {
 	w, h := r.Dx(), r.Dy()
 	dv := make([]uint64, w*h)
@@ -75,15 +113,15 @@ func NewSumY(r image.Rectangle) *SumY {
 }
 
 func SumYFrom(i image.Image, d Model) *SumY {
+	return SumYFromN(i, d, 1)
+}
+
+// SumYFromN is like SumYFrom, but sums the columns of i on n
+// goroutines.
+func SumYFromN(i image.Image, d Model, n int) *SumY {
 	r := i.Bounds()
 	w, h := r.Dx(), r.Dy()
 	dv := make([]uint64, w*h)
-	for x := 0; x < w; x++ {
-		for y, v := 0, uint64(0); y < h; y++ {
-			v += uint64(d.Convert(i.At(x+r.Min.X, y+r.Min.Y)))
-			dv[x*h+y] = v
-		}
-	}
+	sumColumns(dv, r, densities(i, d), n)
 	return &SumY{Values: dv, Stride: h, Rect: r}
-
 }
@@ -0,0 +1,167 @@
+package density
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// The tone models below wrap another Model, and map the densities it
+// gives through a tone curve. They can be wrapped in turn, to apply
+// several curves one after the other. Each keeps the AlphaPolicy of
+// the Model it wraps, and is a FieldModel if that Model is one.
+
+// A table maps the densities of m through a lookup table.
+type table struct {
+	m Model
+	t []uint16
+}
+
+func (t *table) Convert(c color.Color) (d uint16) {
+	return t.t[t.m.Convert(c)]
+}
+
+func (t *table) AlphaPolicy() AlphaPolicy {
+	return PolicyOf(t.m)
+}
+
+// A fieldTable is a table for a FieldModel, and maps its Field.
+type fieldTable struct {
+	*table
+}
+
+func (t *fieldTable) Field(i image.Image) *Map {
+	src := t.m.(FieldModel).Field(i)
+	f := fieldMap(src.Rect)
+	for y := src.Rect.Min.Y; y < src.Rect.Max.Y; y++ {
+		for x := src.Rect.Min.X; x < src.Rect.Max.X; x++ {
+			f.InitSet(x, y, t.t[src.Values[src.DVOffSet(x, y)]])
+		}
+	}
+	return f
+}
+
+// wrap returns t as a Model, which is a FieldModel if the Model that
+// t wraps is one.
+func (t *table) wrap() AlphaModel {
+	if _, ok := t.m.(FieldModel); ok {
+		return &fieldTable{t}
+	}
+	return t
+}
+
+// newTable returns a table for m that maps densities through f,
+// with both its argument and result going from 0 to 1.
+func newTable(m Model, f func(v float64) float64) AlphaModel {
+	t := &table{m: m, t: make([]uint16, 0x10000)}
+	for i := range t.t {
+		t.t[i] = unit(f(float64(i) / 0xFFFF))
+	}
+	return t.wrap()
+}
+
+// Gamma returns a Model that raises the densities of m, from 0 to 1,
+// to the power gamma. A gamma above one lowers the densities of the
+// midtones, and one below raises them.
+func Gamma(m Model, gamma float64) AlphaModel {
+	return newTable(m, func(v float64) float64 { return math.Pow(v, gamma) })
+}
+
+// ContrastBrightness returns a Model that scales the densities of m,
+// from 0 to 1, by contrast around one half, and then adds brightness.
+// The result is clipped to the range of densities.
+func ContrastBrightness(m Model, contrast, brightness float64) AlphaModel {
+	return newTable(m, func(v float64) float64 { return (v-0.5)*contrast + 0.5 + brightness })
+}
+
+// A Knot is a control point of a Curve, which maps the density In to
+// Out, both from 0 to 1.
+type Knot struct {
+	In, Out float64
+}
+
+type byIn []Knot
+
+func (s byIn) Len() int           { return len(s) }
+func (s byIn) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byIn) Less(i, j int) bool { return s[i].In < s[j].In }
+
+// Curve returns a Model that maps the densities of m through the
+// piecewise-linear curve through the knots. Before the first knot and
+// after the last the curve is flat. Without knots, it is the identity.
+func Curve(m Model, knots ...Knot) AlphaModel {
+	ks := make([]Knot, len(knots))
+	copy(ks, knots)
+	sort.Stable(byIn(ks))
+	return newTable(m, func(v float64) float64 {
+		if len(ks) == 0 {
+			return v
+		}
+		i := sort.Search(len(ks), func(i int) bool { return ks[i].In > v })
+		switch {
+		case i == 0:
+			return ks[0].Out
+		case i == len(ks):
+			return ks[i-1].Out
+		}
+		k0, k1 := ks[i-1], ks[i]
+		return k0.Out + (v-k0.In)*(k1.Out-k0.Out)/(k1.In-k0.In)
+	})
+}
+
+// Levels returns a Model that stretches the densities of m from black
+// up to white over the full range of densities, clipping those below
+// black and above white.
+func Levels(m Model, black, white uint16) AlphaModel {
+	lo, hi := float64(black)/0xFFFF, float64(white)/0xFFFF
+	return newTable(m, func(v float64) float64 {
+		if hi <= lo {
+			if v < lo {
+				return 0
+			}
+			return 1
+		}
+		return (v - lo) / (hi - lo)
+	})
+}
+
+// Equalize returns a Model that equalises the histogram of the
+// densities m gives for image i: every density is mapped to the
+// fraction of pixels of i with a lower or equal density, stretched so
+// that the lowest density of i maps to zero. As this takes the
+// statistics of the whole image into account, i is scanned once when
+// calling Equalize. Converting the colours of another image gives
+// densities according to the histogram of i.
+func Equalize(m Model, i image.Image) AlphaModel {
+	var hist [0x10000]uint64
+	r := i.Bounds()
+	at := densities(i, m)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			hist[at(x, y)]++
+		}
+	}
+
+	t := &table{m: m, t: make([]uint16, 0x10000)}
+	var cdf, min, n uint64
+	for _, h := range hist {
+		if min == 0 {
+			min = h
+		}
+		n += h
+	}
+	for v, h := range hist {
+		cdf += h
+		switch {
+		case n == min:
+			// A single density, or none at all: leave it be.
+			t.t[v] = uint16(v)
+		case cdf < min:
+			t.t[v] = 0
+		default:
+			t.t[v] = uint16(((cdf-min)*0xFFFF + (n-min)/2) / (n - min))
+		}
+	}
+	return t.wrap()
+}
@@ -0,0 +1,204 @@
+package density
+
+import (
+	"image"
+	"image/color"
+)
+
+// The views below show the densities of an AreaSummer in a different
+// light, without copying them: they hold nothing but the AreaSummer
+// and a parameter, and work out their sums from its sums when asked.
+// Each view is an AreaSummer itself, so they can be stacked, as in
+//	Clipped{Complement{ds}, r}
+// which is the negative density of a DSum inside r. They are read
+// only; Set the densities of the AreaSummer underneath instead.
+
+// pixel returns the density of a at (x, y).
+func pixel(a AreaSummer, x, y int) uint64 {
+	return a.AreaSum(image.Rect(x, y, x+1, y+1))
+}
+
+// Complement is a view of an AreaSummer in which every density v is
+// replaced by 0xFFFF - v, the "negative" density.
+type Complement struct {
+	AreaSummer
+}
+
+// At returns the density of c at (x, y) as a color.Gray16.
+func (c Complement) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(c.Bounds())) {
+		return color.Gray16{}
+	}
+	return color.Gray16{uint16(0xFFFF - pixel(c.AreaSummer, x, y))}
+}
+
+// AreaSum returns the sum of the densities of c in r, from r.Min up
+// to but not including r.Max.
+func (c Complement) AreaSum(r image.Rectangle) uint64 {
+	r = r.Intersect(c.Bounds())
+	return uint64(r.Dx())*uint64(r.Dy())*0xFFFF - c.AreaSummer.AreaSum(r)
+}
+
+// FindCx returns the x closest to the vertical line that divides the
+// mass of r in half, with r clipped to the bounds of c.
+func (c Complement) FindCx(r image.Rectangle) int { return c.FindQx(r, 0.5) }
+
+// FindCy returns the y closest to the horizontal line that divides
+// the mass of r in half, with r clipped to the bounds of c.
+func (c Complement) FindCy(r image.Rectangle) int { return c.FindQy(r, 0.5) }
+
+// FindQx returns the x closest to the vertical line that puts fraction
+// of the mass of r on its left, with r clipped to the bounds of c.
+func (c Complement) FindQx(r image.Rectangle, fraction float64) int {
+	return areaFindQx(c, r, fraction)
+}
+
+// FindQy returns the y closest to the horizontal line that puts
+// fraction of the mass of r above it, with r clipped to the bounds
+// of c.
+func (c Complement) FindQy(r image.Rectangle, fraction float64) int {
+	return areaFindQy(c, r, fraction)
+}
+
+// Scaled is a view of an AreaSummer in which every density is
+// multiplied by Factor, which should not be negative. At clips the
+// densities to 0xFFFF, but AreaSum does not, and the sums are rounded
+// as a whole rather than per pixel.
+type Scaled struct {
+	AreaSummer
+	Factor float64
+}
+
+// At returns the density of s at (x, y) as a color.Gray16.
+func (s Scaled) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(s.Bounds())) {
+		return color.Gray16{}
+	}
+	v := float64(pixel(s.AreaSummer, x, y))*s.Factor + 0.5
+	if v > 0xFFFF {
+		v = 0xFFFF
+	}
+	return color.Gray16{uint16(v)}
+}
+
+// AreaSum returns the sum of the densities of s in r, from r.Min up
+// to but not including r.Max, rounded to the nearest integer.
+func (s Scaled) AreaSum(r image.Rectangle) uint64 {
+	return uint64(float64(s.AreaSummer.AreaSum(r))*s.Factor + 0.5)
+}
+
+// FindCx returns the x closest to the vertical line that divides the
+// mass of r in half, with r clipped to the bounds of s.
+func (s Scaled) FindCx(r image.Rectangle) int { return s.FindQx(r, 0.5) }
+
+// FindCy returns the y closest to the horizontal line that divides
+// the mass of r in half, with r clipped to the bounds of s.
+func (s Scaled) FindCy(r image.Rectangle) int { return s.FindQy(r, 0.5) }
+
+// FindQx returns the x closest to the vertical line that puts fraction
+// of the mass of r on its left, with r clipped to the bounds of s.
+func (s Scaled) FindQx(r image.Rectangle, fraction float64) int {
+	return areaFindQx(s, r, fraction)
+}
+
+// FindQy returns the y closest to the horizontal line that puts
+// fraction of the mass of r above it, with r clipped to the bounds
+// of s.
+func (s Scaled) FindQy(r image.Rectangle, fraction float64) int {
+	return areaFindQy(s, r, fraction)
+}
+
+// Offset is a view of an AreaSummer in which Delta is added to every
+// density. Like Scaled, At clips the densities to 0xFFFF, but AreaSum
+// does not.
+type Offset struct {
+	AreaSummer
+	Delta uint16
+}
+
+// At returns the density of o at (x, y) as a color.Gray16.
+func (o Offset) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(o.Bounds())) {
+		return color.Gray16{}
+	}
+	v := pixel(o.AreaSummer, x, y) + uint64(o.Delta)
+	if v > 0xFFFF {
+		v = 0xFFFF
+	}
+	return color.Gray16{uint16(v)}
+}
+
+// AreaSum returns the sum of the densities of o in r, from r.Min up
+// to but not including r.Max.
+func (o Offset) AreaSum(r image.Rectangle) uint64 {
+	r = r.Intersect(o.Bounds())
+	return o.AreaSummer.AreaSum(r) + uint64(r.Dx())*uint64(r.Dy())*uint64(o.Delta)
+}
+
+// FindCx returns the x closest to the vertical line that divides the
+// mass of r in half, with r clipped to the bounds of o.
+func (o Offset) FindCx(r image.Rectangle) int { return o.FindQx(r, 0.5) }
+
+// FindCy returns the y closest to the horizontal line that divides
+// the mass of r in half, with r clipped to the bounds of o.
+func (o Offset) FindCy(r image.Rectangle) int { return o.FindQy(r, 0.5) }
+
+// FindQx returns the x closest to the vertical line that puts fraction
+// of the mass of r on its left, with r clipped to the bounds of o.
+func (o Offset) FindQx(r image.Rectangle, fraction float64) int {
+	return areaFindQx(o, r, fraction)
+}
+
+// FindQy returns the y closest to the horizontal line that puts
+// fraction of the mass of r above it, with r clipped to the bounds
+// of o.
+func (o Offset) FindQy(r image.Rectangle, fraction float64) int {
+	return areaFindQy(o, r, fraction)
+}
+
+// Clipped is a view of the part of an AreaSummer inside Rect, as if it
+// were a map with those bounds. Unlike SubMap, it copies nothing.
+type Clipped struct {
+	AreaSummer
+	Rect image.Rectangle
+}
+
+// Bounds returns Rect, clipped to the bounds of the AreaSummer.
+func (c Clipped) Bounds() image.Rectangle {
+	return c.Rect.Intersect(c.AreaSummer.Bounds())
+}
+
+// At returns the density of c at (x, y) as a color.Gray16.
+func (c Clipped) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(c.Bounds())) {
+		return color.Gray16{}
+	}
+	return c.AreaSummer.At(x, y)
+}
+
+// AreaSum returns the sum of the densities of c in r, from r.Min up
+// to but not including r.Max.
+func (c Clipped) AreaSum(r image.Rectangle) uint64 {
+	return c.AreaSummer.AreaSum(r.Intersect(c.Bounds()))
+}
+
+// FindCx returns the x closest to the vertical line that divides the
+// mass of r in half, with r clipped to the bounds of c.
+func (c Clipped) FindCx(r image.Rectangle) int { return c.FindQx(r, 0.5) }
+
+// FindCy returns the y closest to the horizontal line that divides
+// the mass of r in half, with r clipped to the bounds of c.
+func (c Clipped) FindCy(r image.Rectangle) int { return c.FindQy(r, 0.5) }
+
+// FindQx returns the x closest to the vertical line that puts fraction
+// of the mass of r on its left, with r clipped to the bounds of c.
+func (c Clipped) FindQx(r image.Rectangle, fraction float64) int {
+	return areaFindQx(c, r, fraction)
+}
+
+// FindQy returns the y closest to the horizontal line that puts
+// fraction of the mass of r above it, with r clipped to the bounds
+// of c.
+func (c Clipped) FindQy(r image.Rectangle, fraction float64) int {
+	return areaFindQy(c, r, fraction)
+}
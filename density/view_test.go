@@ -0,0 +1,76 @@
+package density
+
+import (
+	"image"
+	"testing"
+)
+
+// testRects returns rectangles in and around the bounds r.
+func testRects(r image.Rectangle) []image.Rectangle {
+	return []image.Rectangle{
+		r,
+		r.Inset(2),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+1, r.Min.Y+1),
+		image.Rect(r.Min.X+3, r.Min.Y+2, r.Max.X-5, r.Min.Y+3),
+		image.Rect(r.Min.X-4, r.Min.Y+1, r.Min.X+6, r.Max.Y+2),
+		r.Add(image.Pt(r.Dx()/2, r.Dy()/2)),
+	}
+}
+
+func TestComplement(t *testing.T) {
+	for _, i := range testImages() {
+		c := Complement{DSumFrom(i, RedDensity)}
+		neg := DSumFrom(i, NegRedDensity)
+		for _, r := range testRects(i.Bounds()) {
+			if got, want := c.AreaSum(r), neg.AreaSum(r); got != want {
+				t.Errorf("%T, %v: AreaSum = %d, want %d", i, r, got, want)
+			}
+			if got, want := c.FindCx(r), neg.FindCx(r); got != want {
+				t.Errorf("%T, %v: FindCx = %d, want %d", i, r, got, want)
+			}
+			if got, want := c.FindCy(r), neg.FindCy(r); got != want {
+				t.Errorf("%T, %v: FindCy = %d, want %d", i, r, got, want)
+			}
+		}
+	}
+}
+
+func TestCubeComplement(t *testing.T) {
+	for _, i := range testImages() {
+		c := CubeComplement{cubeSumOf(i, RedDensity)}
+		neg := cubeSumOf(i, NegRedDensity)
+		b := i.Bounds()
+		for y := b.Min.Y - 1; y <= b.Max.Y; y++ {
+			for x := b.Min.X - 1; x <= b.Max.X; x++ {
+				for z := -1; z <= 2; z++ {
+					if got, want := c.ValueAt(x, y, z), neg.ValueAt(x, y, z); got != want {
+						t.Fatalf("%T: ValueAt(%d, %d, %d) = %d, want %d", i, x, y, z, got, want)
+					}
+				}
+				if !(image.Point{x, y}.In(b)) {
+					continue
+				}
+				if got, want := c.At(x, y), neg.At(x, y); got != want {
+					t.Fatalf("%T: At(%d, %d) = %v, want %v", i, x, y, got, want)
+				}
+			}
+		}
+		for _, r := range testRects(b) {
+			for _, z := range [][2]int{{0, 1}, {0, 2}, {1, 2}, {0, 3}} {
+				if got, want := c.VolumeSum(r, z[0], z[1]), neg.VolumeSum(r, z[0], z[1]); got != want {
+					t.Errorf("%T, %v, %v: VolumeSum = %d, want %d", i, r, z, got, want)
+				}
+				r := r.Intersect(b)
+				if got, want := c.FindCx(r, z[0], z[1]), neg.FindCx(r, z[0], z[1]); got != want {
+					t.Errorf("%T, %v, %v: FindCx = %d, want %d", i, r, z, got, want)
+				}
+				if got, want := c.FindCy(r, z[0], z[1]), neg.FindCy(r, z[0], z[1]); got != want {
+					t.Errorf("%T, %v, %v: FindCy = %d, want %d", i, r, z, got, want)
+				}
+				if got, want := c.FindCz(r, z[0], z[1]), neg.FindCz(r, z[0], z[1]); got != want {
+					t.Errorf("%T, %v, %v: FindCz = %d, want %d", i, r, z, got, want)
+				}
+			}
+		}
+	}
+}
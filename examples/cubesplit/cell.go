@@ -49,9 +49,10 @@ func (c *Cell) Split(splitchan, staticchan chan *Cell) int {
 	cx := c.Source.FindCx(c.Rect, c.zmin, c.zmax)
 	cy := c.Source.FindCy(c.Rect, c.zmin, c.zmax)
 	cz := c.Source.FindCz(c.Rect, c.zmin, c.zmax)
-	ncx := c.Source.FindNegCx(c.Rect, c.zmin, c.zmax)
-	ncy := c.Source.FindNegCy(c.Rect, c.zmin, c.zmax)
-	ncz := c.Source.FindNegCz(c.Rect, c.zmin, c.zmax)
+	neg := density.CubeComplement{CubeSum: c.Source}
+	ncx := neg.FindCx(c.Rect, c.zmin, c.zmax)
+	ncy := neg.FindCy(c.Rect, c.zmin, c.zmax)
+	ncz := neg.FindCz(c.Rect, c.zmin, c.zmax)
 	dx := util.Xweight * intgr.Abs(cx-ncx)
 	dy := util.Yweight * intgr.Abs(cy-ncy)
 	dz := util.Zweight * intgr.Abs(cz-ncz)
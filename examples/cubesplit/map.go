@@ -57,7 +57,7 @@ func NewCube(Rect image.Rectangle, capz int) *Map {
 
 func From(img *image.Image, dm density.Model, capz int) (cube *Map) {
 	cube = new(Map)
-	cube.source = density.CubeSumFrom(img, dm, capz)
+	cube.source = density.CubeSumFrom(*img, dm, capz)
 	cube.Cells = []*Cell{&Cell{
 		Source: cube.source,
 		Rect:   cube.source.Rect,
@@ -68,7 +68,7 @@ func From(img *image.Image, dm density.Model, capz int) (cube *Map) {
 }
 
 func (cube *Map) AddFrame(i *image.Image, dm density.Model) {
-	cube.source.AddFrame(i, dm)
+	cube.source.AddFrame(*i, dm)
 	cube.Cells[0].zmax = cube.source.LenZ
 }
 
@@ -9,6 +9,7 @@ import (
 	"flag"
 	"github.com/kortschak/go-stippling/density"
 	"image"
+	"image/color"
 	"image/jpeg"
 	"image/png"
 	"log"
@@ -25,6 +26,7 @@ func main() {
 	var jpgQuality = flag.Int("q", 90, "\t\tJPG output (q)uality")
 	var generations = flag.Uint("g", 3, "\t\tNumber of (g)enerations")
 	var mono = flag.Bool("m", true, "\t\t(m)onochrome (default) or coloured output")
+	var alphaPolicy = flag.Uint("a", 1, "\t\tHow (a)lpha is treated in coloured output:\n\t\t\t 1 \t premultiplied (default)\n\t\t\t 2 \t unpremultiplied\n\t\t\t 3 \t over white")
 	var saveAll = flag.Bool("s", true, "\t\t(s)ave all generations (default) - only save last generation if false")
 	var numCores = flag.Int("c", 1, "\t\tMax number of (c)ores to be used.\n\t\t\tUse all available cores if less or equal to zero")
 	flag.Parse()
@@ -37,6 +39,17 @@ func main() {
 	}
 	runtime.GOMAXPROCS(nc)
 
+	var alpha func(density.Model) density.Model
+	switch *alphaPolicy {
+	case 1:
+	case 2:
+		alpha = func(m density.Model) density.Model { return density.Unpremultiply(m) }
+	case 3:
+		alpha = func(m density.Model) density.Model { return density.Over(m, color.White) }
+	default:
+		log.Fatal("Unknown alpha policy: ", *alphaPolicy)
+	}
+
 	// Use a function variable for processing the files, so that defer
 	// gets called for closing the fields, but we don't have to pass
 	// all of the variables. This feels dirty way of doing this, but
@@ -100,7 +113,7 @@ func main() {
 			dm.Render(nc)
 			toFile(dm, *generations)
 		} else {
-			cdm := NewColDMap(img, uint(1<<(*generations)))
+			cdm := NewColDMap(img, uint(1<<(*generations)), alpha)
 			for i := uint(0); uint(i) < *generations; i++ {
 				if *saveAll {
 					cdm.Render(nc)
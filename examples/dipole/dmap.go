@@ -299,23 +299,38 @@ func NewDMap(i image.Image, nd, sd density.Model, c uint) (dm *DMap) {
 	return
 }
 
+// ColDMap splits the red, green and blue channel of an image into
+// dipoles separately. alpha wraps the channel models to decide how
+// transparency is treated, see density.AlphaPolicy; if it is nil,
+// the channels stay premultiplied. If alpha unpremultiplies them,
+// the alpha channel is split as well, and ends up in the result.
+// Otherwise the result is opaque.
 type ColDMap struct {
-	R, G, B *DMap
+	R, G, B, A *DMap
 }
 
-func NewColDMap(i image.Image, c uint) (cdm *ColDMap) {
+func NewColDMap(i image.Image, c uint, alpha func(density.Model) density.Model) (cdm *ColDMap) {
 	if c == 0 {
 		c = 1
 	}
+	if alpha == nil {
+		alpha = func(m density.Model) density.Model { return m }
+	}
 	cdm = &ColDMap{
-		R: NewDMap(i, density.RedDensity, density.NegRedDensity, c),
-		G: NewDMap(i, density.GreenDensity, density.NegGreenDensity, c),
-		B: NewDMap(i, density.BlueDensity, density.NegBlueDensity, c),
+		R: NewDMap(i, alpha(density.RedDensity), alpha(density.NegRedDensity), c),
+		G: NewDMap(i, alpha(density.GreenDensity), alpha(density.NegGreenDensity), c),
+		B: NewDMap(i, alpha(density.BlueDensity), alpha(density.NegBlueDensity), c),
+	}
+	if density.PolicyOf(alpha(density.RedDensity)) == density.Unpremultiplied {
+		cdm.A = NewDMap(i, density.AlphaDensity, density.NegAlphaDensity, c)
 	}
 	return
 }
 
 func (c *ColDMap) ColorModel() color.Model {
+	if c.A != nil {
+		return color.NRGBAModel
+	}
 	return color.RGBAModel
 }
 
@@ -327,6 +342,9 @@ func (c *ColDMap) At(x, y int) color.Color {
 	r := uint8(c.R.ValueAt(x, y) >> 8)
 	g := uint8(c.G.ValueAt(x, y) >> 8)
 	b := uint8(c.B.ValueAt(x, y) >> 8)
+	if c.A != nil {
+		return color.NRGBA{r, g, b, uint8(c.A.ValueAt(x, y) >> 8)}
+	}
 	return color.RGBA{r, g, b, 0xFF}
 }
 
@@ -334,10 +352,16 @@ func (c *ColDMap) SplitCells(n int) {
 	c.R.SplitCells(n)
 	c.G.SplitCells(n)
 	c.B.SplitCells(n)
+	if c.A != nil {
+		c.A.SplitCells(n)
+	}
 }
 
 func (c *ColDMap) Render(n int) {
 	c.R.Render(n)
 	c.G.Render(n)
 	c.B.Render(n)
+	if c.A != nil {
+		c.A.Render(n)
+	}
 }
@@ -140,7 +140,7 @@ func main() {
 }
 
 type cell struct {
-	North, South *density.DSum
+	North, South density.AreaSummer
 	Rect         image.Rectangle
 	c            uint16
 }
@@ -153,90 +153,6 @@ func (c *cell) CalcC() {
 	}
 }
 
-// Given a DSum and a Rectangle, finds x closest to line
-// dividing mass in half.
-// Does not do bounds checking - assumes r is inside ds.Rect
-func findcx(ds *density.DSum, r image.Rectangle) int {
-	xmin := r.Min.X
-	xmax := r.Max.X
-	x := (xmax + xmin) / 2
-	vxmaxymax := ds.ValueAt(r.Max.X-1, r.Max.Y-1)
-	vxminymin := ds.ValueAt(r.Min.X-1, r.Min.Y-1)
-	vxminymax := ds.ValueAt(r.Min.X-1, r.Max.Y-1)
-	vxmaxymin := ds.ValueAt(r.Max.X-1, r.Min.Y-1)
-	for {
-		// The centre of mass is probably not a round number,
-		// so we aim to iterate only to the margin of 1 pixel
-		if xmax-xmin > 1 {
-			vcxup := ds.ValueAt(x, r.Min.Y-1)
-			vcxdown := ds.ValueAt(x, r.Max.Y-1)
-			lmass := vcxdown - vcxup - vxminymax + vxminymin
-			rmass := vxmaxymax - vcxdown - vxmaxymin + vcxup
-			if lmass < rmass {
-				xmin = x
-				x = (x + xmax) / 2
-			} else {
-				xmax = x
-				x = (x + xmin) / 2
-			}
-		} else {
-			// Round down to whichever side differs the least from total mass
-			// Note that lmass and rmass are guaranteed to be smaller than total mass
-			lmass := (ds.ValueAt(xmin, r.Max.Y-1) - ds.ValueAt(xmin, r.Min.Y-1) - vxminymax + vxminymin) * 2
-			rmass := (vxmaxymax - ds.ValueAt(xmax, r.Max.Y-1) - vxmaxymin + ds.ValueAt(xmax, r.Min.Y-1)) * 2
-			tmass := vxmaxymax - vxmaxymin - vxminymax + vxminymin
-			if (tmass - lmass) < (tmass - rmass) {
-				return xmin
-			} else {
-				return xmax
-			}
-		}
-	}
-	return x //should never be reached
-}
-
-// Given a DSum and a Rectangle, finds y closest to line
-// dividing mass in half.
-// Does not do bounds checking - assumes r is inside ds.Rect
-func findcy(ds *density.DSum, r image.Rectangle) int {
-	ymin := r.Min.Y
-	ymax := r.Max.Y
-	y := (ymax + ymin) / 2
-	vxmaxymax := ds.ValueAt(r.Max.X-1, r.Max.Y-1)
-	vxminymin := ds.ValueAt(r.Min.X-1, r.Min.Y-1)
-	vxminymax := ds.ValueAt(r.Min.X-1, r.Max.Y-1)
-	vxmaxymin := ds.ValueAt(r.Max.X-1, r.Min.Y-1)
-	for {
-		// The centre of mass is probably not a round number,
-		// so iterate to the margin of 1 pixel
-		if ymax-ymin > 1 {
-			vcyleft := ds.ValueAt(r.Min.X-1, y)
-			vcyright := ds.ValueAt(r.Max.X-1, y)
-			upmass := vcyright - vcyleft - vxmaxymin + vxminymin
-			downmass := vxmaxymax - vcyright - vxminymax + vcyleft
-			if upmass < downmass {
-				ymin = y
-				y = (y + ymax) / 2
-			} else {
-				ymax = y
-				y = (y + ymin) / 2
-			}
-		} else {
-			// Round down to whichever side differs the least from total mass
-			// Note that lmass and rmass are guaranteed to be smaller than total mass
-			upmass := ds.ValueAt(r.Max.X-1, ymin) - ds.ValueAt(r.Min.X-1, ymin) - vxmaxymin + vxminymin
-			downmass := vxmaxymax - ds.ValueAt(r.Max.X-1, ymax) - vxminymax + ds.ValueAt(r.Min.X-1, ymax)
-			tmass := vxmaxymax - vxmaxymin - vxminymax + vxminymin
-			if (tmass - upmass) < (tmass - downmass) {
-				return ymin
-			} else {
-				return ymax
-			}
-		}
-	}
-	return y //should never be reached
-}
-
 // Splits current cell - modifies itself to keep half of
 // the current mass of the cell, returns other half as new cell
 func (c *cell) Split() (child *cell) {
@@ -248,10 +164,10 @@ func (c *cell) Split() (child *cell) {
 		c:     0,
 	}
 
-	ncx := findcx(c.North, c.Rect)
-	ncy := findcy(c.North, c.Rect)
-	scx := findcx(c.South, c.Rect)
-	scy := findcx(c.South, c.Rect)
+	ncx := c.North.FindCx(c.Rect)
+	ncy := c.North.FindCy(c.Rect)
+	scx := c.South.FindCx(c.Rect)
+	scy := c.South.FindCy(c.Rect)
 
 	if intgr.Abs(ncx-scx) > intgr.Abs(ncy-scy) {
 		// split along y axis
@@ -270,8 +186,10 @@ func (c *cell) Split() (child *cell) {
 }
 
 type splitmap struct {
-	north, south *density.DSum
-	cells        []*cell
+	north *density.DSum
+	// south is the complement of north, a view that shares its sums.
+	south density.Complement
+	cells []*cell
 }
 
 func (sp *splitmap) Split() {
@@ -326,7 +244,10 @@ func (sp *splitmap) To(img *image.Gray16) {
 func SPFrom(img image.Image) (sp *splitmap) {
 	sp = new(splitmap)
 	sp.north = density.DSumFrom(img, density.AvgDensity)
-	sp.south = density.DSumFrom(img, density.NegAvgDensity)
+	// The complement is 0xFFFF minus the average density, which
+	// rounds the average up where NegAvgDensity rounds it down, so
+	// it is one less for about a third of the colours.
+	sp.south = density.Complement{AreaSummer: sp.north}
 	sp.cells = []*cell{&cell{
 		North: sp.north,
 		South: sp.south,
@@ -349,13 +270,13 @@ func (csp *colorsplitmap) Split() {
 func CSPFrom(img image.Image) (csp *colorsplitmap) {
 	csp = new(colorsplitmap)
 	csp.R.north = density.DSumFrom(img, density.RedDensity)
-	csp.R.south = density.DSumFrom(img, density.NegRedDensity)
+	csp.R.south = density.Complement{AreaSummer: csp.R.north}
 	csp.G.north = density.DSumFrom(img, density.GreenDensity)
-	csp.G.south = density.DSumFrom(img, density.NegGreenDensity)
+	csp.G.south = density.Complement{AreaSummer: csp.G.north}
 	csp.B.north = density.DSumFrom(img, density.BlueDensity)
-	csp.B.south = density.DSumFrom(img, density.NegBlueDensity)
+	csp.B.south = density.Complement{AreaSummer: csp.B.north}
 	csp.A.north = density.DSumFrom(img, density.AlphaDensity)
-	csp.A.south = density.DSumFrom(img, density.NegAlphaDensity)
+	csp.A.south = density.Complement{AreaSummer: csp.A.north}
 
 	csp.R.cells = []*cell{&cell{
 		North: csp.R.north,
@@ -105,7 +105,7 @@ func colourSplit(files []string, frameNum int) int {
 }
 
 type cell struct {
-	Source *density.DSum
+	Source density.AreaSummer
 	r      image.Rectangle
 	c      uint16
 }
@@ -207,7 +207,7 @@ func (sp *splitmap) To(img *image.Gray16) {
 
 func SPFrom(img *image.Image) (sp *splitmap) {
 	sp = new(splitmap)
-	sp.ds = density.DSumFrom(img, density.AvgDensity)
+	sp.ds = density.DSumFrom(*img, density.AvgDensity)
 	sp.cells = []*cell{&cell{
 		Source: sp.ds,
 		r:      sp.ds.Rect,
@@ -228,10 +228,10 @@ func (csp *colorsplitmap) Split() {
 
 func CSPFrom(img *image.Image) (csp *colorsplitmap) {
 	csp = new(colorsplitmap)
-	csp.R.ds = density.DSumFrom(img, density.RedDensity)
-	csp.G.ds = density.DSumFrom(img, density.GreenDensity)
-	csp.B.ds = density.DSumFrom(img, density.BlueDensity)
-	csp.A.ds = density.DSumFrom(img, density.AlphaDensity)
+	csp.R.ds = density.DSumFrom(*img, density.RedDensity)
+	csp.G.ds = density.DSumFrom(*img, density.GreenDensity)
+	csp.B.ds = density.DSumFrom(*img, density.BlueDensity)
+	csp.A.ds = density.DSumFrom(*img, density.AlphaDensity)
 
 	csp.R.cells = []*cell{&cell{
 		Source: csp.R.ds,
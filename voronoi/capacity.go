@@ -0,0 +1,143 @@
+package voronoi
+
+import (
+	"math"
+)
+
+const (
+	// Maximum number of weight adjustments per iteration of
+	// RelaxCapacity.
+	balanceIterations = 32
+	// Weight adjustments are damped to prevent neighbouring
+	// cells from overshooting each other.
+	balanceDamping = 0.5
+	// Lowest average density (out of 0xFFFF) assumed when
+	// estimating how much a cell has to grow, so that cells
+	// in empty regions do not explode.
+	balanceMinDensity = 0xFFFF / 100
+)
+
+// Balance turns the Diagram into a capacity-constrained one, in
+// which every cell holds the same mass:
+//
+//   total mass / total generators
+//
+// It does so by treating the cells as a power diagram and adjusting
+// the weight of every generator, growing cells with too little mass
+// and shrinking cells with too much. This continues until the mass
+// of every cell is within tolerance (relative to the average, so
+// 0.01 means one percent) or max rounds have passed. If max is zero
+// or less there is no limit. The generators themselves stay put.
+// Returns the largest relative deviation that is left.
+//
+// A Diagram that is not in the Power mode is switched to it first,
+// with the weights of all generators reset to zero.
+func (d *Diagram) Balance(tolerance float64, max int) (dev float64) {
+	switch d.mode {
+	case Unweighted:
+		d.mode = Power
+	case Additive, Multiplicative:
+		d.SetWeights(Power, nil)
+	}
+	if len(d.xsorted) == 0 {
+		return
+	}
+	target := float64(d.dmap.Mass()<<d.fpm) / float64(len(d.xsorted))
+	if target == 0 {
+		return
+	}
+	dw := make([]float64, len(d.xsorted))
+	for i := 0; ; i++ {
+		dev = 0
+		for _, c := range d.xsorted {
+			if cdev := math.Abs(float64(c.mass)-target) / target; cdev > dev {
+				dev = cdev
+			}
+		}
+		if dev <= tolerance || (max > 0 && i >= max) {
+			return
+		}
+
+		wmax := d.wmax()
+		for k, c := range d.xsorted {
+			dw[k] = c.balance(target, wmax, d.fpm.scale())
+		}
+		for k, c := range d.xsorted {
+			c.weight += dw[k]
+		}
+		d.buildAll()
+	}
+}
+
+// balance estimates the change of weight that gives c the target mass.
+// Increasing the weight of a generator by dw moves the bisector with
+// a neighbour at distance d outward by dw/2d, so the area of the cell
+// grows by the sum of the length of its boundaries over 2d, times dw.
+// Empty cells get the weight of the heaviest generator, wmax. s is
+// the size of a pixel in FPM.
+func (c *Cell) balance(target, wmax, s float64) float64 {
+	var g, dmin float64
+	for _, b := range c.boundaries() {
+		if b.neighbour == nil {
+			continue
+		}
+		dx := float64(b.neighbour.X) - float64(c.X)
+		dy := float64(b.neighbour.Y) - float64(c.Y)
+		d := math.Sqrt(dx*dx + dy*dy)
+		if d == 0 {
+			continue
+		}
+		lx := float64(b.p1.X) - float64(b.p0.X)
+		ly := float64(b.p1.Y) - float64(b.p0.Y)
+		g += math.Sqrt(lx*lx+ly*ly) / (2 * d)
+		if dmin == 0 || d < dmin {
+			dmin = d
+		}
+	}
+	if g == 0 {
+		if len(c.left) == 0 {
+			return wmax - c.weight
+		}
+		return 0
+	}
+
+	// Average density of the cell, out of 0xFFFF. Mass is in
+	// FPM, while an area is in squared FPM. A cell too thin
+	// to cover any pixel has no density of its own, and is
+	// treated as the faintest.
+	dens := float64(balanceMinDensity)
+	if total := c.mass + c.nmass; total != 0 {
+		dens = 0xFFFF * float64(c.mass) / float64(total)
+	}
+	if dens < balanceMinDensity {
+		dens = balanceMinDensity
+	}
+	darea := (target - float64(c.mass)) * s / dens
+	dw := balanceDamping * darea / g
+
+	// Never move a bisector more than a quarter of the way
+	// towards the nearest neighbour in one go.
+	if lim := dmin * dmin / 2; dw > lim {
+		dw = lim
+	} else if dw < -lim {
+		dw = -lim
+	}
+	// Distances are in FPM, weights in pixels.
+	return dw / (s * s)
+}
+
+// RelaxCapacity is the capacity-constrained counterpart of Relax: in
+// every iteration the generators are moved to the centre of mass of
+// their cell, after which the Diagram is balanced (see Balance) until
+// the mass of every cell is within tolerance of the average. Compared
+// to plain Lloyd relaxation, this avoids the regular, hexagonal
+// patterns that stipples tend to fall into.
+func (d *Diagram) RelaxCapacity(iterations int, tolerance float64) (dsp []Displacement) {
+	d.Balance(tolerance, balanceIterations)
+	dsp = make([]Displacement, 0, iterations)
+	for i := 0; i < iterations; i++ {
+		dsp = append(dsp, d.relax())
+		d.Balance(tolerance, balanceIterations)
+	}
+	return
+}
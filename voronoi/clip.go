@@ -0,0 +1,442 @@
+package voronoi
+
+import (
+	"math"
+	"math/big"
+	"sort"
+)
+
+// A halfPlane holds the points q that are at least as close to
+// the generator o as to the generator n, in terms of their power
+// distance. With u = q - o and v = n - o, these are the points
+// for which
+//
+//	|u|^2 - wo <= |u - v|^2 - wn
+//	2*u.v - |v|^2 - (wo - wn) <= 0
+//
+// all in FPM, with dw = wo - wn in squared FPM. Generators at the
+// same position and of the same weight do not divide the plane,
+// so fixed decides which one gets all of it: -1 for o, 1 for n.
+type halfPlane struct {
+	o, n   Point
+	vx, vy float64
+	dw     float64
+	fixed  int
+	// line is the bounding line of the half-plane, exactly, for
+	// where the sign of eval is in doubt.
+	line ratLine
+}
+
+// eval gives the value of the left-hand side above at q, and a bound
+// of the error in it. Only the products and the sum round, since the
+// coordinates and their differences are exact in a float64. Where
+// the sign is in doubt, the value is computed exactly instead, which
+// gives an error of at most half an ulp.
+func (h halfPlane) eval(q Point) (f, err float64) {
+	if h.fixed != 0 {
+		return float64(h.fixed), 0
+	}
+	ux, uy := fl(q.X)-fl(h.o.X), fl(q.Y)-fl(h.o.Y)
+	tx, ty, vv := 2*ux*h.vx, 2*uy*h.vy, h.vx*h.vx+h.vy*h.vy
+	f = tx + ty - vv - h.dw
+	err = 8 * epsilon * (math.Abs(tx) + math.Abs(ty) + vv + math.Abs(h.dw))
+	if math.Abs(f) > err {
+		return
+	}
+	f, _ = h.exact(q).Float64()
+	return f, epsilon * math.Abs(f)
+}
+
+// exact gives the value of eval at q exactly.
+func (h halfPlane) exact(q Point) *big.Float {
+	ux, uy := diff(q.X, h.o.X), diff(q.Y, h.o.Y)
+	vx, vy := diff(h.n.X, h.o.X), diff(h.n.Y, h.o.Y)
+	i := new(big.Int).Mul(ux, vx)
+	i.Add(i, new(big.Int).Mul(uy, vy))
+	i.Lsh(i, 1)
+	i.Sub(i, new(big.Int).Mul(vx, vx))
+	i.Sub(i, new(big.Int).Mul(vy, vy))
+	f := new(big.Float).SetPrec(exactPrec).SetInt(i)
+	return f.Sub(f, exact(h.dw))
+}
+
+// bisector gives the half-plane of points that are at least as
+// close to the generator of c as to the generator of n, in terms
+// of their power distance. The weights are in squared pixels, so
+// they are scaled to squared FPM first, with s the size of a pixel
+// in FPM. first tells whether c comes before n in xsorted, which
+// settles it for identical generators.
+func bisector(c, n *Cell, s float64, first bool) (h halfPlane) {
+	h.o, h.n = c.Point, n.Point
+	h.vx, h.vy = fl(n.X)-fl(c.X), fl(n.Y)-fl(c.Y)
+	h.dw = (c.weight - n.weight) * s * s
+	h.line = bisectorLine(c, n, s)
+	if c.Point == n.Point && h.dw == 0 {
+		h.fixed = 1
+		if first {
+			h.fixed = -1
+		}
+	}
+	return
+}
+
+// A polygon is a convex ring of vertices, running clockwise as
+// seen on an image. nb[i] is the neighbouring cell across the
+// edge from v[i] to v[i+1], or nil for edges on the image border.
+// The polygon is the cell c, with s the size of a pixel in FPM.
+type polygon struct {
+	v  []Point
+	nb []*Cell
+	c  *Cell
+	s  float64
+}
+
+func rectPolygon(p0, p1 Point, c *Cell, s float64) polygon {
+	return polygon{
+		v:  []Point{p0, {p1.X, p0.Y}, p1, {p0.X, p1.Y}},
+		nb: make([]*Cell, 4),
+		c:  c,
+		s:  s,
+	}
+}
+
+// edges returns the edges of p as clockwise Boundaries.
+func (p *polygon) edges() (bs []Boundary) {
+	bs = make([]Boundary, len(p.v))
+	for i, p0 := range p.v {
+		bs[i] = Boundary{p0: p0, p1: p.v[(i+1)%len(p.v)], neighbour: p.nb[i]}
+	}
+	return
+}
+
+// clip cuts away the part of the polygon outside of h, using
+// Sutherland-Hodgman. The new edge along h gets n as neighbour.
+// Which side of h a vertex is on is decided exactly, for the vertex
+// where the lines of its edges cross rather than for its rounded
+// position, so that collinear and identical generators give proper
+// cells, and every cell ends up with the same edges, however short,
+// as the cells around it.
+func (p *polygon) clip(h halfPlane, n *Cell) {
+	if len(p.v) == 0 {
+		return
+	}
+	f := make([]float64, len(p.v))
+	e := make([]float64, len(p.v))
+	exact := make([]bool, len(p.v))
+	for i := range p.v {
+		f[i], e[i], exact[i] = p.side(i, h)
+	}
+	v := make([]Point, 0, len(p.v)+1)
+	nb := make([]*Cell, 0, len(p.v)+1)
+	for i, p0 := range p.v {
+		j := (i + 1) % len(p.v)
+		switch {
+		case f[i] <= 0 && f[j] <= 0:
+			v, nb = append(v, p0), append(nb, p.nb[i])
+		case f[i] <= 0:
+			v, nb = append(v, p0, p.cut(i, h, f, e, exact)), append(nb, p.nb[i], n)
+		case f[j] <= 0:
+			v, nb = append(v, p.cut(i, h, f, e, exact)), append(nb, p.nb[i])
+		}
+	}
+
+	// Remove edges that have been reduced to a single point.
+	p.v, p.nb = p.v[:0], p.nb[:0]
+	for i, q := range v {
+		if q != v[(i+1)%len(v)] {
+			p.v, p.nb = append(p.v, q), append(p.nb, nb[i])
+		}
+	}
+	if len(p.v) < 3 {
+		p.v, p.nb = p.v[:0], p.nb[:0]
+	}
+}
+
+// side gives the value of h.eval for vertex i of p, and the error in
+// it. The rounded position of a vertex lies within an FPM of the exact
+// one, which can change the value by up to 2*(|vx| + |vy|). Where that
+// leaves the sign in doubt, it is decided exactly instead, and exact is
+// true.
+func (p *polygon) side(i int, h halfPlane) (f, err float64, exact bool) {
+	f, err = h.eval(p.v[i])
+	if h.fixed != 0 || math.Abs(f) > err+2*(math.Abs(h.vx)+math.Abs(h.vy))+epsilon*math.Abs(h.dw) {
+		return f, err, false
+	}
+	x, y := p.vertex(i)
+	f, _ = h.line.at(x, y).Float64()
+	return f, 0, true
+}
+
+// vertex gives vertex i of p exactly, as the point where the lines of
+// the edges that meet there cross. Where there are no such lines, as
+// in the corners of the image, it is the vertex itself.
+func (p *polygon) vertex(i int) (x, y *big.Rat) {
+	l0, ok0 := p.line((i + len(p.v) - 1) % len(p.v))
+	l1, ok1 := p.line(i)
+	if ok0 && ok1 {
+		if x, y, ok := l0.cross(l1); ok {
+			return x, y
+		}
+	}
+	return new(big.Rat).SetUint64(p.v[i].X), new(big.Rat).SetUint64(p.v[i].Y)
+}
+
+// cut gives the point where edge i of p crosses the line bounding h,
+// given the values f and their errors e of all vertices. Where the
+// side of either end was decided exactly, so is the point.
+func (p *polygon) cut(i int, h halfPlane, f, e []float64, exact []bool) Point {
+	j := (i + 1) % len(p.v)
+	if exact[i] || exact[j] {
+		if l, ok := p.line(i); ok {
+			if x, y, ok := l.cross(h.line); ok {
+				return Point{round(x), round(y)}
+			}
+		}
+	}
+	return intersect(p.v[i], p.v[j], h, f[i], f[j], e[i]+e[j])
+}
+
+// intersect gives the point where the edge from p0 to p1 crosses the
+// line bounding h, given the values f0 and f1 of eval at both ends,
+// which must have opposite signs, and the error err in them. If that
+// error could move the point by more than a quarter FPM, the point
+// is computed exactly instead.
+func intersect(p0, p1 Point, h halfPlane, f0, f1, err float64) Point {
+	dx, dy := fl(p1.X)-fl(p0.X), fl(p1.Y)-fl(p0.Y)
+	if err/math.Abs(f0-f1)*math.Max(math.Abs(dx), math.Abs(dy)) < 0.25 {
+		t := f0 / (f0 - f1)
+		return Point{uint64(fl(p0.X) + t*dx + 0.5), uint64(fl(p0.Y) + t*dy + 0.5)}
+	}
+
+	x0, x1 := h.exact(p0), h.exact(p1)
+	t := new(big.Float).SetPrec(exactPrec).Sub(x0, x1)
+	t.Quo(x0, t)
+	at := func(a, b uint64) uint64 {
+		d := new(big.Float).SetPrec(exactPrec).SetInt(diff(b, a))
+		d.Mul(d, t)
+		d.Add(d, new(big.Float).SetUint64(a))
+		d.Add(d, big.NewFloat(0.5))
+		u, _ := d.Uint64()
+		return u
+	}
+	return Point{at(p0.X, p1.X), at(p0.Y, p1.Y)}
+}
+
+// snap moves every vertex of p to where the lines of the edges that
+// meet there cross. Clipping places a vertex where the last bisector
+// cuts an edge, rounded, which depends on the order in which the
+// bisectors came. Solved exactly from the generators around it, every
+// vertex comes out the same for all the cells that share it. p0 and
+// p1 are the bounds of the image.
+func (p *polygon) snap(p0, p1 Point) {
+	if len(p.v) == 0 {
+		return
+	}
+	v := make([]Point, len(p.v))
+	for i := range p.v {
+		x, y := p.vertex(i)
+		v[i] = Point{clamp(x, p0.X, p1.X), clamp(y, p0.Y, p1.Y)}
+	}
+
+	// Vertices that ended up on top of each other are merged.
+	w, nb := p.v[:0], p.nb[:0]
+	for i, q := range v {
+		if q != v[(i+1)%len(v)] {
+			w, nb = append(w, q), append(nb, p.nb[i])
+		}
+	}
+	p.v, p.nb = w, nb
+	if len(p.v) < 3 {
+		p.v, p.nb = p.v[:0], p.nb[:0]
+	}
+}
+
+// A ratLine holds the points q for which a*q.X + b*q.Y = e, exactly.
+type ratLine struct {
+	a, b, e *big.Rat
+}
+
+// bisectorLine gives the line of the points with the same power
+// distance to the generators of c and n, see halfPlane:
+//
+//	2*(n - c).q = |n|^2 - |c|^2 - (wn - wc)
+//
+// with s the size of a pixel in FPM.
+func bisectorLine(c, n *Cell, s float64) (l ratLine) {
+	sq := func(x uint64) *big.Int {
+		i := new(big.Int).SetUint64(x)
+		return i.Mul(i, i)
+	}
+	l.a = new(big.Rat).SetInt(diff(n.X, c.X))
+	l.a.Add(l.a, l.a)
+	l.b = new(big.Rat).SetInt(diff(n.Y, c.Y))
+	l.b.Add(l.b, l.b)
+	e := new(big.Int).Add(sq(n.X), sq(n.Y))
+	e.Sub(e, sq(c.X))
+	e.Sub(e, sq(c.Y))
+	dw := new(big.Rat).SetFloat64(c.weight)
+	dw.Sub(dw, new(big.Rat).SetFloat64(n.weight))
+	dw.Mul(dw, new(big.Rat).SetFloat64(s*s))
+	l.e = dw.Add(dw, new(big.Rat).SetInt(e))
+	return
+}
+
+// line gives the line along edge i of p: the bisector of p.c and the
+// neighbour across it, or the border of the image, which runs along
+// either axis. ok is false for an edge on the border that does not.
+func (p *polygon) line(i int) (l ratLine, ok bool) {
+	if n := p.nb[i]; n != nil {
+		return bisectorLine(p.c, n, p.s), true
+	}
+	q0, q1 := p.v[i], p.v[(i+1)%len(p.v)]
+	switch {
+	case q0.X == q1.X:
+		return ratLine{big.NewRat(1, 1), new(big.Rat), new(big.Rat).SetUint64(q0.X)}, true
+	case q0.Y == q1.Y:
+		return ratLine{new(big.Rat), big.NewRat(1, 1), new(big.Rat).SetUint64(q0.Y)}, true
+	}
+	return l, false
+}
+
+// at gives a*x + b*y - e, which is negative on the side of the line
+// where the generator of the cell lies.
+func (l ratLine) at(x, y *big.Rat) *big.Rat {
+	v := new(big.Rat).Mul(l.a, x)
+	v.Add(v, new(big.Rat).Mul(l.b, y))
+	return v.Sub(v, l.e)
+}
+
+// cross gives the point where l and m cross. ok is false if they are
+// parallel.
+func (l ratLine) cross(m ratLine) (x, y *big.Rat, ok bool) {
+	mul := func(a, b *big.Rat) *big.Rat { return new(big.Rat).Mul(a, b) }
+	det := mul(l.a, m.b)
+	det.Sub(det, mul(m.a, l.b))
+	if det.Sign() == 0 {
+		return nil, nil, false
+	}
+	x = mul(l.e, m.b)
+	x.Sub(x, mul(m.e, l.b))
+	y = mul(l.a, m.e)
+	y.Sub(y, mul(m.a, l.e))
+	return x.Quo(x, det), y.Quo(y, det), true
+}
+
+// round rounds the non-negative x to the nearest integer, and
+// negative x to zero.
+func round(x *big.Rat) uint64 {
+	if x.Sign() < 0 {
+		return 0
+	}
+	return roundRat(x)
+}
+
+// clamp rounds x to the nearest integer from lo up to and including
+// hi.
+func clamp(x *big.Rat, lo, hi uint64) uint64 {
+	switch {
+	case x.Cmp(new(big.Rat).SetUint64(lo)) <= 0:
+		return lo
+	case x.Cmp(new(big.Rat).SetUint64(hi)) >= 0:
+		return hi
+	}
+	return roundRat(x)
+}
+
+// reach gives how far a generator can be from the generator of
+// a cell, while its bisector still cuts the cell. r2 is the squared
+// radius of the cell and dw the largest amount by which the weight
+// of another generator exceeds that of the cell. The bisector lies
+// at (d^2 + wc - wn)/2d from the generator of the cell, which is
+// beyond the radius of the cell once d > r + sqrt(r^2 + dw).
+func reach(r2 float64, dw float64) float64 {
+	if dw < 0 {
+		dw = 0
+	}
+	r := math.Sqrt(r2)
+	return r + math.Sqrt(r2+dw)
+}
+
+// radius gives the squared distance from q to the most remote
+// vertex of p.
+func (p *polygon) radius(q Point) (r float64) {
+	for _, v := range p.v {
+		if d := dist2(v, q); d > r {
+			r = d
+		}
+	}
+	return
+}
+
+// buildAll updates the boundaries and mass of every cell, after the
+// generators moved or their weights changed.
+//
+// This is not a sweep-line construction: every cell is built on its
+// own by build, which clips the image against the bisectors of the
+// generators in order of their distance along the x-axis, and stops
+// once the next one is out of reach. For generators spread evenly over
+// the image, a cell meets O(√n) of them that way, so that the whole
+// takes O(n√n); it degrades to O(n²) when most generators share a
+// column. The curved modes are traced instead, see trace and
+// vicinities.
+func (d *Diagram) buildAll() {
+	sort.Sort(byX(d.xsorted))
+	sort.Sort(byY(d.ysorted))
+	wmax := d.wmax()
+	var vs map[*Cell]*vicinity
+	if d.mode.curved() {
+		p0, p1 := d.maps.bounds()
+		vs = d.vicinities(d.xsorted, wmax, p0, p1)
+	}
+	for k, c := range d.xsorted {
+		d.build(k, c, wmax, vs[c])
+	}
+}
+
+// build updates the boundaries and mass of c, which is at index k in
+// xsorted, with wmax the weight of the heaviest generator. For the
+// curved modes, v is the vicinity of c.
+func (d *Diagram) build(k int, c *Cell, wmax float64, v *vicinity) {
+	if d.mode.curved() {
+		d.trace(c, v, wmax)
+		d.maps.integrate(c)
+		return
+	}
+
+	// General procedure: every cell starts out as the whole
+	// image, and is then cut down by the bisectors with the
+	// generators around it. Since the cells are sorted along
+	// the x-axis, we work outwards from the generator, nearest
+	// x first. The cell fits in a circle with the distance to
+	// its most remote vertex as radius, so once the next
+	// generator is more than twice that distance away along
+	// the x-axis (or a bit more, with weights, see reach) its
+	// bisector cannot cut the cell anymore - and neither can
+	// those of any generators after it.
+	p0, p1 := d.maps.bounds()
+	s := d.fpm.scale()
+	poly := rectPolygon(p0, p1, c, s)
+	r := poly.radius(c.Point)
+	for lo, hi := k-1, k+1; lo >= 0 || hi < len(d.xsorted); {
+		var n *Cell
+		var dx int64
+		var after bool
+		if lo >= 0 {
+			n, dx = d.xsorted[lo], int64(c.X-d.xsorted[lo].X)
+		}
+		if hi < len(d.xsorted) && (n == nil || int64(d.xsorted[hi].X-c.X) < dx) {
+			n, dx, after = d.xsorted[hi], int64(d.xsorted[hi].X-c.X), true
+			hi++
+		} else {
+			lo--
+		}
+		if float64(dx) > reach(r, (wmax-c.weight)*s*s) {
+			break
+		}
+		poly.clip(bisector(c, n, s, after), n)
+		r = poly.radius(c.Point)
+	}
+	poly.snap(p0, p1)
+	c.setBoundaries(poly.edges())
+	d.maps.integrate(c)
+}
@@ -0,0 +1,63 @@
+package voronoi
+
+import (
+	"github.com/kortschak/go-stippling/density"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testImage returns a w by h image with a gradient across it and a
+// pattern on top, so that the generators end up unevenly spread.
+func testImage(w, h int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{uint8((x*255)/w ^ (y * 3))})
+		}
+	}
+	return img
+}
+
+// checkShared reports every edge of a cell in d that its neighbour
+// does not have, the other way round.
+func checkShared(t *testing.T, d *Diagram) {
+	for _, c := range d.xsorted {
+		for _, b := range c.boundaries() {
+			n := b.neighbour
+			if n == nil {
+				continue
+			}
+			found := false
+			for _, nb := range n.boundaries() {
+				if nb.neighbour == c && nb.p0 == b.p1 && nb.p1 == b.p0 {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Errorf("edge %v-%v of cell at %v missing from neighbour at %v", b.p0, b.p1, c.Point, n.Point)
+			}
+		}
+	}
+}
+
+func TestSharedVertices(t *testing.T) {
+	for _, n := range []uint64{2, 50, 500} {
+		d := NewDiagram(testImage(300, 200), density.AvgDensity, n)
+		checkShared(t, d)
+	}
+}
+
+func TestSharedVerticesGrid(t *testing.T) {
+	// Generators on a grid meet four at a vertex, where
+	// rounding is most likely to set cells apart.
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	d := NewDiagram(img, density.AvgDensity, 0)
+	for y := 4; y < 64; y += 8 {
+		for x := 4; x < 64; x += 8 {
+			d.Insert(FromFloat(float64(x), float64(y), d.Precision()))
+		}
+	}
+	checkShared(t, d)
+}
@@ -0,0 +1,119 @@
+package voronoi
+
+import (
+	"sort"
+)
+
+// An Edge of the Delaunay triangulation connects the generators of
+// two neighbouring cells.
+type Edge struct {
+	A, B *Cell
+}
+
+// A Triangle of the Delaunay triangulation connects the generators of
+// three cells that meet in a single vertex of the Diagram. A, B and C
+// run clockwise as seen on an image.
+type Triangle struct {
+	A, B, C *Cell
+}
+
+// Delaunay returns the triangulation that is dual to the Diagram:
+// every pair of neighbouring cells gives an edge, and every vertex
+// where three cells meet gives a triangle. For the Unweighted mode
+// that is the Delaunay triangulation of the generators, and for the
+// Power mode the weighted (regular) one. Empty cells have no part in
+// it. As cells are clipped to the image, triangles with their vertex
+// outside of it are missing, which can happen along the convex hull
+// of the generators.
+//
+// Where four cells meet in a single vertex, as happens with generators
+// on a common circle (or, with Power weights, with generators whose
+// lifted points share a plane), the quadrilateral they form is split
+// along the diagonal through the cell that comes first in xsorted.
+// More than four cells meeting in a vertex is not resolved, and gives
+// no triangles.
+//
+// The curved modes give the dual graph of the Diagram in the same
+// way, but cells can neighbour each other more than once, so the
+// triangles need not form a proper triangulation, and four cells
+// meeting in a vertex give no triangles either.
+func (d *Diagram) Delaunay() (ts []Triangle, es []Edge) {
+	index := make(map[*Cell]int, len(d.xsorted))
+	for i, c := range d.xsorted {
+		index[c] = i
+	}
+	neighbours := make([]map[*Cell]bool, len(d.xsorted))
+	for i, c := range d.xsorted {
+		neighbours[i] = make(map[*Cell]bool)
+		for _, n := range c.Neighbours() {
+			neighbours[i][n] = true
+			if index[n] > i {
+				es = append(es, Edge{c, n})
+			}
+		}
+	}
+
+	// Walking around a cell, every vertex between boundaries with
+	// two different neighbours is shared with those neighbours. To
+	// give every triangle once, only the cell that comes first in
+	// xsorted adds it.
+	for i, c := range d.xsorted {
+		for _, ch := range c.chains() {
+			for k, b := range ch {
+				n0, n1 := b.neighbour, ch[(k+1)%len(ch)].neighbour
+				if n0 == nil || n1 == nil || n0 == n1 || index[n0] < i || index[n1] < i {
+					continue
+				}
+				if neighbours[index[n0]][n1] {
+					ts = append(ts, triangle(c, n0, n1))
+					continue
+				}
+				// Four cells on a common circle, with the one
+				// opposite c neighbouring both n0 and n1. The
+				// circle is only meaningful for straight
+				// bisectors, see powerIncircle.
+				if d.mode.curved() {
+					continue
+				}
+				for x := range neighbours[index[n0]] {
+					if x == c || index[x] < i || !neighbours[index[n1]][x] || neighbours[i][x] {
+						continue
+					}
+					t := triangle(c, n0, n1)
+					if powerIncircle(t.A, t.B, t.C, x, d.fpm.scale()) == 0 {
+						ts = append(ts, triangle(c, n0, x), triangle(c, x, n1))
+						break
+					}
+				}
+			}
+		}
+	}
+	if d.mode.curved() {
+		ts = uniqueTriangles(ts, index)
+	}
+	return
+}
+
+// triangle gives the Triangle of a, b and c, turned clockwise.
+func triangle(a, b, c *Cell) Triangle {
+	if orient(a.Point, b.Point, c.Point) < 0 {
+		return Triangle{a, c, b}
+	}
+	return Triangle{a, b, c}
+}
+
+// uniqueTriangles removes duplicate triangles from ts, which occur when
+// the same three cells meet in more than one vertex.
+func uniqueTriangles(ts []Triangle, index map[*Cell]int) []Triangle {
+	seen := make(map[[3]int]bool, len(ts))
+	u := ts[:0]
+	for _, t := range ts {
+		k := []int{index[t.A], index[t.B], index[t.C]}
+		sort.Ints(k)
+		if key := [3]int{k[0], k[1], k[2]}; !seen[key] {
+			seen[key] = true
+			u = append(u, t)
+		}
+	}
+	return u
+}
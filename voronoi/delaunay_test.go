@@ -0,0 +1,45 @@
+package voronoi
+
+import (
+	"github.com/kortschak/go-stippling/density"
+	"image"
+	"testing"
+)
+
+func TestDelaunayFourCells(t *testing.T) {
+	// Four generators that are not on a common circle, but with
+	// Power weights that make their cells meet in the single
+	// vertex v.
+	ps := [][2]float64{{20, 20}, {44, 18}, {46, 44}, {16, 40}}
+	v := [2]float64{32, 31}
+	for _, test := range []struct {
+		mode  WeightMode
+		edges int
+	}{
+		// Without weights, two of the cells share a short
+		// edge across the middle instead.
+		{Unweighted, 5},
+		{Power, 4},
+	} {
+		d := NewDiagram(image.NewGray(image.Rect(0, 0, 64, 64)), density.AvgDensity, 0)
+		for _, p := range ps {
+			d.Insert(FromFloat(p[0], p[1], d.Precision()))
+		}
+		d.SetWeights(test.mode, func(p Point) float64 {
+			x, y := p.Float(d.Precision())
+			return (x-v[0])*(x-v[0]) + (y-v[1])*(y-v[1])
+		})
+		ts, es := d.Delaunay()
+		if len(ts) != 2 {
+			t.Errorf("mode %d: got %d triangles, want 2", test.mode, len(ts))
+		}
+		for _, tr := range ts {
+			if orient(tr.A.Point, tr.B.Point, tr.C.Point) <= 0 {
+				t.Errorf("mode %d: triangle %v not clockwise", test.mode, tr)
+			}
+		}
+		if len(es) != test.edges {
+			t.Errorf("mode %d: got %d edges, want %d", test.mode, len(es), test.edges)
+		}
+	}
+}
@@ -1,5 +1,94 @@
 package voronoi
 
-const (
-	fpmbits = 10
+import (
+	"image"
+	"math"
+	"math/bits"
 )
+
+// DefaultPrecision is the number of fractional bits of the FPM used
+// by NewDiagram: 1024 by 1024 subpixels.
+const DefaultPrecision = 10
+
+// MaxPrecision gives the largest number of fractional bits that a
+// Diagram of an image with bounds r can use without the masses of
+// its cells overflowing. Every bit of precision halves the range of
+// the masses, so huge images have to make do with less precision,
+// while small images can use more.
+func MaxPrecision(r image.Rectangle) uint {
+	w, h := uint64(r.Dx()), uint64(r.Dy())
+	side := w
+	if h > side {
+		side = h
+	}
+	// The mass of a slice of a row or column is multiplied by its
+	// coverage in FPM before it is shifted back, which takes
+	// 16 bits of density, the length of the row or column and
+	// twice the precision. The mass of the whole image takes the
+	// area and the precision.
+	p := (48 - bits.Len64(side)) / 2
+	if a := 48 - bits.Len64(w*h); a < p {
+		p = a
+	}
+	if p < 0 {
+		return 0
+	}
+	return uint(p)
+}
+
+// An fpm holds the precision of the FPM of a Diagram, the number
+// of fractional bits of its coordinates and masses.
+type fpm uint
+
+func (f fpm) one() uint64 { return 1 << f }
+
+func (f fpm) frac() uint64 { return f.one() - 1 }
+
+// scale gives the size of a pixel in FPM, to convert distances
+// in pixels to FPM.
+func (f fpm) scale() float64 { return float64(f.one()) }
+
+// FromFloat gives the Point at (x, y), in pixels, as FPM with prec
+// fractional bits, rounded to the nearest subpixel. Negative values
+// are taken to be zero.
+func FromFloat(x, y float64, prec uint) Point {
+	s := math.Ldexp(1, int(prec))
+	return Point{uint64(math.Max(x*s, 0) + 0.5), uint64(math.Max(y*s, 0) + 0.5)}
+}
+
+// Float gives the position of p in pixels, with p having prec
+// fractional bits.
+func (p Point) Float(prec uint) (x, y float64) {
+	return math.Ldexp(float64(p.X), -int(prec)), math.Ldexp(float64(p.Y), -int(prec))
+}
+
+// Add returns the vector p+q.
+func (p Point) Add(q Point) Point {
+	return Point{p.X + q.X, p.Y + q.Y}
+}
+
+// Sub returns the vector p-q. As the coordinates are unsigned, q
+// must not be greater than p along either axis.
+func (p Point) Sub(q Point) Point {
+	return Point{p.X - q.X, p.Y - q.Y}
+}
+
+// Scale returns the vector p*f, rounded to the nearest subpixel.
+// f must not be negative.
+func (p Point) Scale(f float64) Point {
+	return Point{uint64(float64(p.X)*f + 0.5), uint64(float64(p.Y)*f + 0.5)}
+}
+
+// Floor returns p rounded down to whole pixels, with p having prec
+// fractional bits. The result is still in FPM; shift it right by
+// prec to get the pixel itself.
+func (p Point) Floor(prec uint) Point {
+	return p.Sub(p.Frac(prec))
+}
+
+// Frac returns the fractional part of p, with p having prec
+// fractional bits, so that p == p.Floor(prec).Add(p.Frac(prec)).
+func (p Point) Frac(prec uint) Point {
+	f := fpm(prec).frac()
+	return Point{p.X & f, p.Y & f}
+}
@@ -0,0 +1,176 @@
+package voronoi
+
+import (
+	"math"
+	"sort"
+)
+
+// Insert adds a generator at p and returns its cell, or nil if p lies
+// outside of the image. The generator gets a weight of zero, or of one
+// in the Multiplicative mode.
+//
+// Only the cells that lose part of their area to the new one are
+// rebuilt. These are found by starting at the cell that holds p, and
+// spreading out over the neighbours of every cell that turns out to
+// have a vertex closer to p than to its own generator. The cell that
+// holds p is always rebuilt, as the new cell may be an island in it
+// that does not reach any of its vertices.
+func (d *Diagram) Insert(p Point) (c *Cell) {
+	return d.insert(p, d.mode.defaultWeight())
+}
+
+// insert adds a generator with weight w at p, see Insert.
+func (d *Diagram) insert(p Point, w float64) (c *Cell) {
+	p0, p1 := d.maps.bounds()
+	if p.X < p0.X || p.Y < p0.Y || p.X >= p1.X || p.Y >= p1.Y {
+		return nil
+	}
+	c = &Cell{Point: p, weight: w}
+
+	var affected []*Cell
+	if start := d.CellAt(p.Float(d.Precision())); start != nil {
+		seen := map[*Cell]bool{start: true}
+		for queue := []*Cell{start}; len(queue) > 0; queue = queue[1:] {
+			k := queue[0]
+			if k != start && !d.loses(k, c) {
+				continue
+			}
+			affected = append(affected, k)
+			for _, n := range k.Neighbours() {
+				if !seen[n] {
+					seen[n] = true
+					queue = append(queue, n)
+				}
+			}
+		}
+	}
+
+	r0, r1 := d.around(affected)
+	d.xsorted = insertCell(d.xsorted, c, func(i int) bool { return !lessX(d.xsorted[i], c) })
+	d.ysorted = insertCell(d.ysorted, c, func(i int) bool { return !lessY(d.ysorted[i], c) })
+	d.rebuild(append(affected, c), r0, r1)
+	return
+}
+
+// Remove takes c out of the Diagram. Only the cells that take over
+// part of its area are rebuilt. With straight bisectors, these are its
+// former neighbours and any empty cells, as they may appear again.
+// With curved ones, a generator that is nearest after that of c
+// somewhere within it need not have been its neighbour: the cell of a
+// heavy generator can fall apart in the Multiplicative mode, with a
+// piece of it appearing inside the area of c. The generators that can
+// own part of the squares over c (see squares) are rebuilt instead.
+func (d *Diagram) Remove(c *Cell) {
+	i := sort.Search(len(d.xsorted), func(i int) bool { return !lessX(d.xsorted[i], c) })
+	j := sort.Search(len(d.ysorted), func(i int) bool { return !lessY(d.ysorted[i], c) })
+	for i < len(d.xsorted) && d.xsorted[i] != c && d.xsorted[i].Point == c.Point {
+		i++
+	}
+	for j < len(d.ysorted) && d.ysorted[j] != c && d.ysorted[j].Point == c.Point {
+		j++
+	}
+	if i == len(d.xsorted) || d.xsorted[i] != c || j == len(d.ysorted) || d.ysorted[j] != c {
+		return
+	}
+	d.xsorted = append(d.xsorted[:i], d.xsorted[i+1:]...)
+	d.ysorted = append(d.ysorted[:j], d.ysorted[j+1:]...)
+
+	var affected []*Cell
+	if d.mode.curved() {
+		p0, p1 := d.around([]*Cell{c})
+		seen := make(map[int]bool)
+		d.squares(p0, p1, d.wmax(), func(_, _ Point, marked []int) {
+			for _, i := range marked {
+				if !seen[i] {
+					seen[i] = true
+					affected = append(affected, d.xsorted[i])
+				}
+			}
+		})
+	} else {
+		affected = c.Neighbours()
+		for _, k := range d.xsorted {
+			if len(k.left) == 0 {
+				affected = append(affected, k)
+			}
+		}
+	}
+	r0, r1 := d.around(append([]*Cell{c}, affected...))
+	d.rebuild(affected, r0, r1)
+}
+
+// loses reports whether any vertex of k is closer to the generator of
+// c than to that of k, that is, whether k would give up area to c. A
+// generator inserted on top of an identical one goes first in xsorted,
+// so that k loses all of its area.
+func (d *Diagram) loses(k, c *Cell) bool {
+	if k.Point == c.Point && k.weight == c.weight {
+		return true
+	}
+	for _, b := range k.boundaries() {
+		for _, p := range [2]Point{b.p0, b.p1} {
+			x, y := float64(p.X), float64(p.Y)
+			if d.dist(c, x, y) < d.dist(k, x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rebuild updates the boundaries and mass of the given cells only,
+// which have to lie within the rectangle r0, r1 once rebuilt. Every
+// cell is built from the generators alone, with its vertices solved
+// exactly (see polygon.snap), so the cells come out the same as when
+// the whole Diagram is built. For the curved modes, only the part of
+// the image within r0, r1 is searched for the vicinities of the cells;
+// the tracing does not depend on how much more of the image that takes
+// in, as long as it holds the cells.
+func (d *Diagram) rebuild(cs []*Cell, r0, r1 Point) {
+	wmax := d.wmax()
+	var vs map[*Cell]*vicinity
+	if d.mode.curved() {
+		vs = d.vicinities(cs, wmax, r0, r1)
+	}
+	for _, c := range cs {
+		k := sort.Search(len(d.xsorted), func(i int) bool { return !lessX(d.xsorted[i], c) })
+		for d.xsorted[k] != c {
+			k++
+		}
+		d.build(k, c, wmax, vs[c])
+	}
+}
+
+// around gives the rectangle that holds the given cells, widened by a
+// pixel on every side to make up for the rounding of their vertices.
+// The cells that take over the area of others after an Insert or
+// Remove lie within it. If none of the cells has any area, it is the
+// whole image.
+func (d *Diagram) around(cs []*Cell) (p0, p1 Point) {
+	i0, i1 := d.maps.bounds()
+	p0 = Point{math.MaxUint64, math.MaxUint64}
+	for _, c := range cs {
+		if len(c.left) == 0 {
+			continue
+		}
+		q0, q1 := c.extent()
+		p0 = Point{minU(p0.X, q0.X), minU(p0.Y, q0.Y)}
+		p1 = Point{maxU(p1.X, q1.X), maxU(p1.Y, q1.Y)}
+	}
+	if p0.X > p1.X {
+		return i0, i1
+	}
+	s := d.fpm.one()
+	p0 = Point{maxU(p0.X, i0.X+s) - s, maxU(p0.Y, i0.Y+s) - s}
+	p1 = Point{minU(p1.X+s, i1.X), minU(p1.Y+s, i1.Y)}
+	return
+}
+
+// insertCell inserts c into s at the first index for which at is true.
+func insertCell(s []*Cell, c *Cell, at func(i int) bool) []*Cell {
+	i := sort.Search(len(s), at)
+	s = append(s, nil)
+	copy(s[i+1:], s[i:])
+	s[i] = c
+	return s
+}
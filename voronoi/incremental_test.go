@@ -0,0 +1,81 @@
+package voronoi
+
+import (
+	"github.com/kortschak/go-stippling/density"
+	"image"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestIncrementalRebuild(t *testing.T) {
+	for _, mode := range []WeightMode{Unweighted, Additive, Multiplicative, Power} {
+		d := NewDiagram(testImage(120, 80), density.AvgDensity, 40)
+		d.SetWeights(mode, func(p Point) float64 {
+			v := float64((p.X*7+p.Y*13)>>d.Precision()%5) + 1
+			switch mode {
+			case Additive:
+				return v
+			case Multiplicative:
+				return 1 + v/10
+			}
+			return v * 10
+		})
+		// New generators are lighter than all others, heavier
+		// than all others, or get the default weight.
+		ws := map[WeightMode][]float64{
+			Unweighted:     {0},
+			Additive:       {0.2, 8, 0},
+			Multiplicative: {0.6, 2.5, 1},
+			Power:          {2, 90, 0},
+		}[mode]
+		rnd := rand.New(rand.NewSource(1))
+		for i := 0; i < 12; i++ {
+			d.insert(FromFloat(rnd.Float64()*120, rnd.Float64()*80, d.Precision()), ws[i%len(ws)])
+			cs := d.Cells()
+			d.Remove(cs[rnd.Intn(len(cs))])
+		}
+		checkRebuild(t, d)
+	}
+}
+
+func TestInsertIsland(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	d := NewDiagram(img, density.AvgDensity, 2)
+	d.xsorted[0].Point = FromFloat(25, 50, d.Precision())
+	d.xsorted[1].Point = FromFloat(75, 50, d.Precision())
+	d.SetWeights(Multiplicative, func(p Point) float64 { return 3 })
+
+	// The new cell is a disc well within the one on the left, and
+	// does not reach any of its vertices.
+	c := d.Insert(FromFloat(30, 50, d.Precision()))
+	if c.Neighbours()[0] != d.xsorted[0] || len(c.Neighbours()) != 1 {
+		t.Fatalf("new cell is not an island in the one on the left")
+	}
+	checkRebuild(t, d)
+	d.Remove(c)
+	checkRebuild(t, d)
+}
+
+// checkRebuild reports every cell in d whose mass or boundaries change
+// when the whole Diagram is built again.
+func checkRebuild(t *testing.T, d *Diagram) {
+	type cell struct {
+		mass, nmass uint64
+		bs          []Boundary
+	}
+	got := make(map[*Cell]cell)
+	for _, c := range d.xsorted {
+		got[c] = cell{c.mass, c.nmass, c.boundaries()}
+	}
+	d.buildAll()
+	for _, c := range d.xsorted {
+		want := cell{c.mass, c.nmass, c.boundaries()}
+		if !reflect.DeepEqual(got[c], want) {
+			t.Errorf("mode %d: cell at %v differs from a full rebuild: mass %d, want %d", d.mode, c.Point, got[c].mass, want.mass)
+		}
+	}
+}
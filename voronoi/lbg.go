@@ -0,0 +1,117 @@
+package voronoi
+
+import (
+	"math"
+)
+
+// LBGStep summarises a single iteration of LBG stippling: how many
+// cells were split and removed, and how far the generators moved.
+type LBGStep struct {
+	Split, Removed int
+	Displacement
+}
+
+// LBG applies Linde-Buzo-Gray stippling to the Diagram, as described by
+// Deussen et al. Every iteration, the generators are moved to the centre
+// of mass of their cell, like in Relax. After that, cells with a mass
+// well above the target mass of
+//
+//   total mass / ncells
+//
+// are split in two, and cells with a mass well below it are removed.
+// Well above or below means outside of the band of hysteresis around
+// the target, relative to it: with a hysteresis of 0.5, cells between
+// 0.75 and 1.25 times the target mass are left alone. To guarantee
+// convergence, the band widens by growth every iteration.
+//
+// This continues until an iteration neither splits nor removes any
+// cells, or max iterations have passed. If max is zero or less there
+// is no limit. Returns the progress of every iteration.
+func (d *Diagram) LBG(ncells uint64, hysteresis, growth float64, max int) (steps []LBGStep) {
+	if ncells == 0 {
+		return
+	}
+	target := float64(d.dmap.Mass()<<d.fpm) / float64(ncells)
+	for i := 0; max <= 0 || i < max; i++ {
+		var s LBGStep
+		if len(d.xsorted) > 0 {
+			s.Displacement = d.relax()
+		}
+
+		h := hysteresis + float64(i)*growth
+		lo, hi := target*(1-h/2), target*(1+h/2)
+		var light, heavy []*Cell
+		for _, c := range d.xsorted {
+			switch m := float64(c.mass); {
+			case m < lo:
+				light = append(light, c)
+			case m > hi:
+				heavy = append(heavy, c)
+			}
+		}
+		for _, c := range light {
+			d.Remove(c)
+		}
+		for _, c := range heavy {
+			d.split(c)
+		}
+		s.Split, s.Removed = len(heavy), len(light)
+		steps = append(steps, s)
+		if s.Split == 0 && s.Removed == 0 {
+			break
+		}
+	}
+	return
+}
+
+// split replaces c by two generators on either side of its centre of
+// mass, along the principal axis of the cell. Each lies on the centre
+// of its half of the cell, were the cell uniformly spread along the
+// axis: sqrt(3) times the standard deviation along it, over two. The
+// new generators keep the weight of c.
+func (d *Diagram) split(c *Cell) {
+	ux, uy, v := c.axis()
+	off := math.Sqrt(3*v) / 2
+	if off < 1 {
+		off = 1
+	}
+	p0, p1 := d.maps.bounds()
+	at := func(s float64) Point {
+		x := math.Min(math.Max(float64(c.cm.X)+s*ux, float64(p0.X)), float64(p1.X-1))
+		y := math.Min(math.Max(float64(c.cm.Y)+s*uy, float64(p0.Y)), float64(p1.Y-1))
+		return Point{uint64(x + 0.5), uint64(y + 0.5)}
+	}
+	d.Remove(c)
+	d.insert(at(-off), c.weight)
+	d.insert(at(off), c.weight)
+}
+
+// axis gives the principal axis of the area of c as the unit vector
+// (ux, uy), along with the variance along it, in squared FPM. The
+// second moments of area are summed over the edges of its rings.
+func (c *Cell) axis() (ux, uy, v float64) {
+	var a, sx, sy, sxx, syy, sxy float64
+	for _, r := range c.Rings() {
+		for i, p := range r {
+			q := r[(i+1)%len(r)]
+			// Relative to the generator, to keep the numbers small.
+			x0, y0 := float64(p.X)-float64(c.X), float64(p.Y)-float64(c.Y)
+			x1, y1 := float64(q.X)-float64(c.X), float64(q.Y)-float64(c.Y)
+			cross := x0*y1 - x1*y0
+			a += cross / 2
+			sx += (x0 + x1) * cross / 6
+			sy += (y0 + y1) * cross / 6
+			sxx += (x0*x0 + x0*x1 + x1*x1) * cross / 12
+			syy += (y0*y0 + y0*y1 + y1*y1) * cross / 12
+			sxy += (x0*y1 + 2*x0*y0 + 2*x1*y1 + x1*y0) * cross / 24
+		}
+	}
+	if a == 0 {
+		return 1, 0, 0
+	}
+	mx, my := sx/a, sy/a
+	vx, vy, cov := sxx/a-mx*mx, syy/a-my*my, sxy/a-mx*my
+	theta := math.Atan2(2*cov, vx-vy) / 2
+	v = (vx+vy)/2 + math.Hypot((vx-vy)/2, cov)
+	return math.Cos(theta), math.Sin(theta), v
+}
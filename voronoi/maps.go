@@ -8,246 +8,165 @@ type maps struct {
 	dmap *density.Map
 	sumx *density.SumX
 	sumy *density.SumY
-	dsum *density.DSum
+	sums *density.MomentSum
+	fpm
 }
 
-// mass(p0, p1) gives the mass as over the area in p0 and p1,
-// as an int64 with 10-bit FPM.
-func (m *maps) subMass(p0, p1 Point) (mass uint64) {
-	x0 := int(p0.X >> fpmbits)
-	y0 := int(p0.Y >> fpmbits)
-	x1 := int(p1.X >> fpmbits)
-	y1 := int(p1.Y >> fpmbits)
-	fxp0 := (0x400 - (p0.X & 0x3FF)) & 0x400
-	fyp0 := (0x400 - (p0.Y & 0x3FF)) & 0x400
-	fxp1 := p1.X & 0x3FF
-	fyp1 := p1.Y & 0x3FF
-
-	// top-left corner and bottem-left corner
-	if fxp0 != 0 {
-		if fyp0 != 0 {
-			mass += (fxp0 * fyp0 * m.dmap.ValueAt(x0, y0)) >> fpmbits
-		} else {
-			mass += fxp0 * m.dmap.ValueAt(x0, y0)
-		}
-
-		if fyp1 != 0 {
-			mass += (fxp0 * fyp1 * m.dmap.ValueAt(x0, y1)) >> fpmbits
-		}
-	} else {
-		if fyp0 != 0 {
-			mass += fyp0 * m.dmap.ValueAt(x0, y0)
-		} else {
-			mass += m.dmap.ValueAt(x0, y0) << fpmbits
-		}
-		if fyp1 != 0 {
-			mass += fyp1 * m.dmap.ValueAt(x0, y1)
-		}
-	}
-
-	// top right corner and bottom-right corner
-	if fxp1 != 0 {
-		if fyp0 != 0 {
-			mass += (fxp1 * fyp0 * m.dmap.ValueAt(x1, y0)) >> fpmbits
-		}
-		if fyp1 != 0 {
-			mass += (fxp1 * fyp1 * m.dmap.ValueAt(x1, y1)) >> fpmbits
-		}
-	}
-
-	// top factional row, without fractional corners
-	if fyp0 != 0 {
-		mass += fyp0 * (m.sumx.ValueAt(x1, y0) - m.sumx.ValueAt(x0, y0))
-	}
-
-	// bottom fractional row without fractional corners
-	if fyp1 != 0 {
-		mass += fyp1 * (m.sumx.ValueAt(x1, y1) - m.sumx.ValueAt(x0, y1))
-	}
+// bounds returns the top-left and bottom-right corner of the maps
+// as FPM Points.
+func (m *maps) bounds() (p0, p1 Point) {
+	// Theoretically, rect.Min can be negative, but since we open
+	// the images ourselves we know that will never happen, and
+	// in fact it is guaranteed that the values will be zero.
+	r := m.dmap.Bounds()
+	p0 = Point{uint64(r.Min.X) << m.fpm, uint64(r.Min.Y) << m.fpm}
+	p1 = Point{uint64(r.Max.X) << m.fpm, uint64(r.Max.Y) << m.fpm}
+	return
+}
 
-	// left-most fractional column, without fractional corners
-	if fxp0 != 0 {
-		mass += fxp0 * (m.sumy.ValueAt(x0, y1) - m.sumy.ValueAt(x0, y0))
+// rowMass gives the mass of pixel row y from x0 up to x1, as an
+// uint64 in FPM. The row is taken to be fully covered along the
+// y-axis.
+func (m *maps) rowMass(y int, x0, x1 uint64) (mass uint64) {
+	if x1 <= x0 {
+		return
 	}
-
-	// right-most fractional column, without fractional corners
-	if fxp1 != 0 {
-		mass += fxp1 * (m.sumy.ValueAt(x1, y1) - m.sumy.ValueAt(x1, y0))
+	px0 := int(x0 >> m.fpm)
+	px1 := int(x1 >> m.fpm)
+	if px0 == px1 {
+		return (x1 - x0) * m.dmap.ValueAt(px0, y)
 	}
 
-	// center area, without fractional borders
-	mass += m.dsum.AreaSum(x0, y0, x1, y1) << fpmbits
+	// Fractional pixels on both ends, whole pixels in between.
+	one, frac := m.fpm.one(), m.fpm.frac()
+	mass = (one-(x0&frac))*m.dmap.ValueAt(px0, y) + (x1&frac)*m.dmap.ValueAt(px1, y)
+	mass += (m.sumx.ValueAt(px1-1, y) - m.sumx.ValueAt(px0, y)) << m.fpm
+	return
 }
 
-// Gives the centre of mass of the area enclosed by p0 and p1
-func (m *maps) cm(p0, p1 Point) (c Point) {
-	var mass, dm uint64
-
-	x0 := int(p0.X >> fpmbits)
-	y0 := int(p0.Y >> fpmbits)
-	x1 := int(p1.X >> fpmbits)
-	y1 := int(p1.Y >> fpmbits)
-	fxp0 := (0x400 - (p0.X & 0x3FF)) & 0x400
-	fyp0 := (0x400 - (p0.Y & 0x3FF)) & 0x400
-	fxp1 := p1.X & 0x3FF
-	fyp1 := p1.Y & 0x3FF
-
-	// Finding WX. I hate FPM... Self-documenting in
-	// an attempt keep overview of structure.
-
-	// Leftmost column first.
-
-	// Check if top-left corner is a fraction in y-axis
-	if fyp0 != 0 {
-		dm = fyp0 * m.dmap.ValueAt(x0, y0)
-	} else {
-		dm = m.dmap.ValueAt(x0, y0) << fpmbits
+// colMass gives the mass of pixel column x from y0 up to y1, as an
+// uint64 in FPM. The column is taken to be fully covered along the
+// x-axis.
+func (m *maps) colMass(x int, y0, y1 uint64) (mass uint64) {
+	if y1 <= y0 {
+		return
 	}
-
-	// Leftmost column without corners
-	dm += (m.sumy.ValueAt(x0, y1) - m.sumy.ValueAt(x0, y0)) << fpmbits
-
-	// check if bottom-left corner is a fraction in y-axis
-	if fyp1 != 0 {
-		dm += fyp1 * m.dmap.ValueAt(x0, y1)
+	py0 := int(y0 >> m.fpm)
+	py1 := int(y1 >> m.fpm)
+	if py0 == py1 {
+		return (y1 - y0) * m.dmap.ValueAt(x, py0)
 	}
 
-	// check is leftmost column + corners are a fraction
-	// in the x axis, last correction, add everything to
-	// c.X and mass
-	if fxp0 != 0 {
-		c.X = (uint64(x0) * fxp0 * dm) >> fpmbits
-		mass = (fxp0 * dm) >> fpmbits
-	} else {
-		c.X = uint64(x0) * dm
-		mass = dm
-	}
+	one, frac := m.fpm.one(), m.fpm.frac()
+	mass = (one-(y0&frac))*m.dmap.ValueAt(x, py0) + (y1&frac)*m.dmap.ValueAt(x, py1)
+	mass += (m.sumy.ValueAt(x, py1-1) - m.sumy.ValueAt(x, py0)) << m.fpm
+	return
+}
 
-	//Middle columns, without left and right columns or bottom row
+// subMass(p0, p1) gives the mass over the area in p0 and p1,
+// as an uint64 in FPM.
+func (m *maps) subMass(p0, p1 Point) (mass uint64) {
+	return m.sums.FixedSubMass(int64(p0.X), int64(p0.Y), int64(p1.X), int64(p1.Y), uint(m.fpm))
+}
 
-	// Correct top-most row without corners, if a fraction
-	if fyp0 != 0 {
-		for x := x0 + 1; x < x1; x++ {
-			dm = m.dmap.ValueAt(x, y0) * fyp0
-			c.X += uint64(x) * dm
+// A spanFunc appends the extent of an area along the line through t
+// on the other axis to s, as pairs [s0, s1), all FPM. Nothing is
+// appended when the line does not cross the area.
+type spanFunc func(t uint64, s []uint64) []uint64
+
+// rows integrates the density over the rows from y0 up to y1, with
+// the extent of each row given by span. Rows are further cut into
+// slices at the (sorted) breaks, usually the vertices of an area.
+// Every slice is sampled halfway, which is exact as long as its
+// extent changes linearly within it. Returns the mass and negative
+// mass in FPM, and the mass-weighted y. The latter is a float64
+// because it easily overflows 64 bits on larger images.
+func (m *maps) rows(y0, y1 uint64, breaks []uint64, span spanFunc) (mass, nmass uint64, wy float64) {
+	var s []uint64
+	for y := y0; y < y1; {
+		next := m.nextSlice(y, y1, &breaks)
+		t := (y + next) >> 1
+		cov := next - y
+		s = span(t, s[:0])
+		for i := 0; i+1 < len(s); i += 2 {
+			x0, x1 := s[i], s[i+1]
+			dm := (m.rowMass(int(y>>m.fpm), x0, x1) * cov) >> m.fpm
 			mass += dm
+			nmass += (((x1 - x0) * 0xFFFF * cov) >> m.fpm) - dm
+			wy += float64(dm) * float64(t)
 		}
-	} else {
-		y0--
-	}
-
-	for x := x0 + 1; x < x1; x++ {
-		dm = (m.sumy.ValueAt(x, y1) - m.sumy.ValueAt(x, y0))
-		c.X += uint64(x) * dm
-		mass += dm
-	}
-
-	if fyp0 == 0 {
-		y0++
+		y = next
 	}
+	return
+}
 
-	// Bottom row, excluding corners
-	if fyp1 != 0 {
-		for x := x0 + 1; x < x1; x++ {
-			dm = m.dmap.ValueAt(x, y1) * fyp1
-			c.X += uint64(x) * dm
+// cols is the column equivalent of rows, returning the mass and the
+// mass-weighted x.
+func (m *maps) cols(x0, x1 uint64, breaks []uint64, span spanFunc) (mass uint64, wx float64) {
+	var s []uint64
+	for x := x0; x < x1; {
+		next := m.nextSlice(x, x1, &breaks)
+		t := (x + next) >> 1
+		s = span(t, s[:0])
+		for i := 0; i+1 < len(s); i += 2 {
+			dm := (m.colMass(int(x>>m.fpm), s[i], s[i+1]) * (next - x)) >> m.fpm
 			mass += dm
+			wx += float64(dm) * float64(t)
 		}
+		x = next
 	}
+	return
+}
 
-	// Check if rightmost column is a fraction in the x-axis
-	if fxp1 != 0 {
-		if fyp0 != 0 {
-			dm = fyp0 * m.dmap.ValueAt(x1, y0)
-		}
-
-		// Rightmost column without corners
-		dm += (m.sumy.ValueAt(x1, y1) - m.sumy.ValueAt(x1, y0)) << fpmbits
-
-		// check if bottom-right corner is a fraction in y-axis
-		if fyp1 != 0 {
-			dm += fyp1 * m.dmap.ValueAt(x1, y1)
-		}
-
-		// Correct for fraction, add to c.X and mass
-		c.X = (uint64(x1) * fxp1 * dm) >> fpmbits
-		mass = (fxp1 * dm) >> fpmbits
-	}
-
-	// Find WY. Similar procedure to WX, without the mass part.
-
-	// Topmost row first.
-
-	// Check if top-left corner is a fraction in x-axis
-	if fxp0 != 0 {
-		dm = fxp0 * m.dmap.ValueAt(x0, y0)
-	} else {
-		dm = m.dmap.ValueAt(x0, y0) << fpmbits
+// nextSlice gives the end of the slice starting at t: the next pixel
+// edge or break, whichever comes first, but no further than max.
+func (m *maps) nextSlice(t, max uint64, breaks *[]uint64) (next uint64) {
+	next = (t | m.fpm.frac()) + 1
+	for len(*breaks) > 0 && (*breaks)[0] <= t {
+		*breaks = (*breaks)[1:]
 	}
-
-	// Topmost row without corners
-	dm += (m.sumx.ValueAt(x1, y0) - m.sumx.ValueAt(x0, y0)) << fpmbits
-
-	// check if top-right corner is a fraction in x-axis
-	if fxp1 != 0 {
-		dm += fyp1 * m.dmap.ValueAt(x1, y0)
+	if len(*breaks) > 0 && (*breaks)[0] < next {
+		next = (*breaks)[0]
 	}
-
-	// check if topmost row + corners are a fraction
-	// in the y axis, last correction, add everything to
-	// c.Y and mass
-	if fyp0 != 0 {
-		c.Y = (uint64(y0) * fyp0 * dm) >> fpmbits
-	} else {
-		c.Y = uint64(y0) * dm
-	}
-
-	// Middle rows, without top and bottom rows and rightmost column
-
-	// Correct left-most column without corners, if a fraction
-	if fxp0 != 0 {
-		for y := y0 + 1; y < y1; y++ {
-			c.Y += m.dmap.ValueAt(x0, y) * fxp0
-		}
-	} else {
-		x0--
+	if next > max {
+		next = max
 	}
+	return
+}
 
-	for y := y0 + 1; y < y1; y++ {
-		c.Y += uint64(y) * (m.sumx.ValueAt(x0, y) - m.sumx.ValueAt(x0, y))
-	}
+// Gives the centre of mass of the area enclosed by p0 and p1. If the
+// area has no mass, its geometric centre is returned instead.
+func (m *maps) cm(p0, p1 Point) (c Point) {
+	x, y := m.sums.FixedSubCM(int64(p0.X), int64(p0.Y), int64(p1.X), int64(p1.Y), uint(m.fpm))
+	return Point{uint64(x), uint64(y)}
+}
 
-	if fxp0 == 0 {
-		x0++
-	}
+// integrate updates the mass, negative mass and centre of mass of
+// cell c, based on its current boundaries. A cell without any
+// boundaries has its centre of mass on the generator.
+func (m *maps) integrate(c *Cell) {
+	if len(c.left) == 0 {
+		c.mass, c.nmass, c.cm = 0, 0, c.Point
+		return
+	}
+	p0, p1 := c.extent()
+	xbreaks, ybreaks := c.vertices()
+	var xmass uint64
+	var wx, wy float64
+	c.mass, c.nmass, wy = m.rows(p0.Y, p1.Y, ybreaks, c.rowSpan)
+	xmass, wx = m.cols(p0.X, p1.X, xbreaks, c.colSpan)
+	c.cm = centroid(xmass, c.mass, wx, wy, Point{(p0.X + p1.X) >> 1, (p0.Y + p1.Y) >> 1})
+}
 
-	// Rightmost column, excluding corners
-	if fxp1 != 0 {
-		for y := y0 + 1; y < y1; y++ {
-			c.Y += m.dmap.ValueAt(x0, y) * fxp1
-		}
+// centroid corrects the weighted x and y for mass, falling back
+// to the geometric centre g when there is no mass to speak of.
+func centroid(xmass, ymass uint64, wx, wy float64, g Point) (c Point) {
+	c = g
+	if xmass != 0 {
+		c.X = uint64(wx/float64(xmass) + 0.5)
 	}
-
-	// Check if bottom row is a fraction in the y-axis
-	if fyp1 != 0 {
-		if fxp0 != 0 {
-			dm = fxp0 * m.dmap.ValueAt(x0, y1)
-		}
-
-		// Rightmost column without corners
-		dm += (m.sumx.ValueAt(x1, y1) - m.sumx.ValueAt(x0, y1)) << fpmbits
-
-		// check if bottom-right corner is a fraction in y-axis
-		if fxp1 != 0 {
-			dm += fxp1 * m.dmap.ValueAt(x1, y1)
-		}
-
-		// Correct for fraction, add to c.Y
-		c.Y = (uint64(x1) * fyp1 * dm) >> fpmbits
+	if ymass != 0 {
+		c.Y = uint64(wy/float64(ymass) + 0.5)
 	}
-
-	// correct X and Y for mass
-	c.X /= mass
-	c.Y /= mass
 	return
 }
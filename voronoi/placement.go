@@ -0,0 +1,263 @@
+package voronoi
+
+import (
+	"math"
+	"math/rand"
+)
+
+// A Placement is a strategy for putting generators on the image.
+// All of them give the same generators, in the same order, for the
+// same seed.
+type Placement int
+
+const (
+	// Bisection splits the image in two parts with the mass divided
+	// as evenly as the generators, until every part holds a single
+	// generator on its centre of mass. It does not use the seed.
+	Bisection Placement = iota
+	// Rejection draws every generator at random, with a probability
+	// proportional to the density at its position.
+	Rejection
+	// PoissonDisc draws generators like Rejection, but turns down
+	// the ones that come too close to an earlier generator. Where
+	// the density is high they may come closer together than where
+	// it is low, so that every generator covers about the same
+	// mass. This gives a blue noise distribution.
+	PoissonDisc
+)
+
+const (
+	// Starting distance between the generators of PoissonDisc, as a
+	// fraction of the square root of the area each generator covers
+	// on average. As long as generators are turned down poissonTries
+	// times in a row, the distance shrinks by poissonShrink.
+	poissonSpacing = 0.7
+	poissonTries   = 30
+	poissonShrink  = 0.9
+)
+
+// Place replaces the generators of the Diagram by ncells new ones,
+// put on the image according to placement p with the given seed,
+// and rebuilds the cells. The generators get a weight of zero, or of
+// one in the Multiplicative mode.
+func (d *Diagram) Place(p Placement, ncells uint64, seed int64) {
+	var ps []Point
+	switch p {
+	case Rejection:
+		ps = d.maps.rejection(ncells, rand.New(rand.NewSource(seed)))
+	case PoissonDisc:
+		ps = d.maps.poissonDisc(ncells, rand.New(rand.NewSource(seed)))
+	default:
+		if ncells > 0 {
+			p0, p1 := d.maps.bounds()
+			ps = guess(d.maps, p0, p1, ncells, make([]Point, 0, ncells))
+		}
+	}
+
+	w := d.mode.defaultWeight()
+	d.xsorted = make([]*Cell, len(ps))
+	d.ysorted = make([]*Cell, len(ps))
+	for i, p := range ps {
+		c := &Cell{Point: p, weight: w}
+		d.xsorted[i] = c
+		d.ysorted[i] = c
+	}
+	d.buildAll()
+}
+
+// The guessing algorithm is based on the simple observation that once
+// in equilibrium, all generators have mass equal to:
+//
+//   total mass / total generators
+//
+// Hence, the following should result in a decent initial guess: Take
+// the density map, split it in two along the longest axis such that
+// the cells can be divided as evenly as possible among the submaps,
+// and the mass is divided proportionaly to the number of cells being
+// divided. Repeat this process with the submaps, until there is only
+// one cell left in a submap, which will have average mass. To get an
+// early start on Floyd relaxation, the center of the generator is
+// then put on the centre of mass of this submap.
+//
+// The generators are appended to ps depth first, top-left submap
+// before bottom-right, so that their order does not change between
+// runs.
+func guess(m maps, p0, p1 Point, ncells uint64, ps []Point) []Point {
+	if ncells == 1 {
+		return append(ps, m.cm(p0, p1))
+	}
+	n0 := ncells >> 1
+	n1 := ncells - n0
+	mass := m.subMass(p0, p1)
+	// The mass of a large image in FPM leaves too few bits to
+	// multiply by n0 directly.
+	targetmass := mulDivFloor(n0, mass, ncells)
+
+	dx := p1.X - p0.X
+	dy := p1.Y - p0.Y
+	dp := p1
+
+	// divide along longest axis, bisecting until we
+	// hit the first line with at least targetmass.
+	if dx < dy {
+		lo, hi := p0.Y, p1.Y
+		for lo < hi {
+			dp.Y = lo + (hi-lo)>>1
+			if m.subMass(p0, dp) < targetmass {
+				lo = dp.Y + 1
+			} else {
+				hi = dp.Y
+			}
+		}
+		dp.Y = lo
+		ps = guess(m, p0, dp, n0, ps)
+		dp.X = p0.X
+		return guess(m, dp, p1, n1, ps)
+	}
+	lo, hi := p0.X, p1.X
+	for lo < hi {
+		dp.X = lo + (hi-lo)>>1
+		if m.subMass(p0, dp) < targetmass {
+			lo = dp.X + 1
+		} else {
+			hi = dp.X
+		}
+	}
+	dp.X = lo
+	ps = guess(m, p0, dp, n0, ps)
+	dp.Y = p0.Y
+	return guess(m, dp, p1, n1, ps)
+}
+
+// A sampler draws points from the density map, with a probability
+// proportional to the density. Where the map is empty, every point
+// is as likely.
+type sampler struct {
+	m      *maps
+	p0, p1 Point
+	max    uint64
+	rnd    *rand.Rand
+}
+
+func newSampler(m *maps, rnd *rand.Rand) *sampler {
+	s := &sampler{m: m, rnd: rnd}
+	s.p0, s.p1 = m.bounds()
+	for _, v := range m.dmap.Values {
+		if uint64(v) > s.max {
+			s.max = uint64(v)
+		}
+	}
+	return s
+}
+
+// density gives the density of the pixel that holds p, which is the
+// highest density for an empty map.
+func (s *sampler) density(p Point) uint64 {
+	if s.max == 0 {
+		return 1
+	}
+	return s.m.dmap.ValueAt(int(p.X>>s.m.fpm), int(p.Y>>s.m.fpm))
+}
+
+// next draws a point uniformly from the image until one is accepted,
+// with the density over the highest density as the probability.
+func (s *sampler) next() (p Point) {
+	for {
+		p.X = s.p0.X + uint64(s.rnd.Int63n(int64(s.p1.X-s.p0.X)))
+		p.Y = s.p0.Y + uint64(s.rnd.Int63n(int64(s.p1.Y-s.p0.Y)))
+		if s.max == 0 || uint64(s.rnd.Int63n(int64(s.max))) < s.density(p) {
+			return
+		}
+	}
+}
+
+// rejection draws ncells generators by rejection sampling.
+func (m *maps) rejection(ncells uint64, rnd *rand.Rand) (ps []Point) {
+	p0, p1 := m.bounds()
+	if ncells == 0 || p0.X == p1.X || p0.Y == p1.Y {
+		return
+	}
+	s := newSampler(m, rnd)
+	ps = make([]Point, ncells)
+	for i := range ps {
+		ps[i] = s.next()
+	}
+	return
+}
+
+// poissonDisc draws ncells generators by dart throwing. A candidate
+// at p is turned down if an earlier generator lies within
+//
+//   spacing * sqrt(mass per generator / density at p)
+//
+// with the mass per generator in pixels times density. To find the
+// earlier generators, they are kept in a grid with the smallest such
+// distance as its spacing.
+func (m *maps) poissonDisc(ncells uint64, rnd *rand.Rand) (ps []Point) {
+	p0, p1 := m.bounds()
+	if ncells == 0 || p0.X == p1.X || p0.Y == p1.Y {
+		return
+	}
+	s := newSampler(m, rnd)
+	mass := float64(m.dmap.Mass())
+	if s.max == 0 {
+		mass = float64(m.dmap.Rect.Dx() * m.dmap.Rect.Dy())
+	}
+	// Squared radius per unit of density, in squared FPM.
+	scale := m.fpm.scale()
+	r2 := mass / float64(ncells) * scale * scale
+
+	gs := math.Ceil(poissonSpacing * math.Sqrt(r2/float64(s.max|1)))
+	if gs < 1 {
+		gs = 1
+	}
+	gw := int(float64(p1.X-p0.X)/gs) + 1
+	gh := int(float64(p1.Y-p0.Y)/gs) + 1
+	grid := make([][]int, gw*gh)
+
+	spacing := poissonSpacing
+	ps = make([]Point, 0, ncells)
+	for misses := 0; uint64(len(ps)) < ncells; {
+		p := s.next()
+		r := spacing * math.Sqrt(r2/float64(s.density(p)))
+		gx, gy := int(float64(p.X-p0.X)/gs), int(float64(p.Y-p0.Y)/gs)
+		if !vacant(ps, grid, gw, gh, gx, gy, int(r/gs)+1, p, r) {
+			if misses++; misses == poissonTries {
+				misses, spacing = 0, spacing*poissonShrink
+			}
+			continue
+		}
+		misses = 0
+		grid[gy*gw+gx] = append(grid[gy*gw+gx], len(ps))
+		ps = append(ps, p)
+	}
+	return
+}
+
+// vacant reports whether none of the points ps in the grid cells
+// within reach of gx, gy lies within r of p.
+func vacant(ps []Point, grid [][]int, gw, gh, gx, gy, reach int, p Point, r float64) bool {
+	x0, x1, y0, y1 := gx-reach, gx+reach, gy-reach, gy+reach
+	if x0 < 0 {
+		x0 = 0
+	}
+	if y0 < 0 {
+		y0 = 0
+	}
+	if x1 >= gw {
+		x1 = gw - 1
+	}
+	if y1 >= gh {
+		y1 = gh - 1
+	}
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			for _, i := range grid[y*gw+x] {
+				if dist2(ps[i], p) < r*r {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,192 @@
+package voronoi
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+)
+
+// The geometric predicates below are evaluated in float64 first. The
+// coordinates of a Point take at most 48 bits (see MaxPrecision), so
+// their differences are exact, and only the products and sums that
+// follow round. Following Shewchuk, the error of that is
+// bounded by a small multiple of the sum of the magnitudes of the
+// terms. If the result is further from zero than that, its sign is
+// right; if not, it is computed again exactly with math/big. The
+// half-planes of clip.go, and the intersections of their lines with
+// the edges of a polygon, work the same way.
+
+const (
+	epsilon = 1.0 / (1 << 53)
+	// Error bounds of the orient and incircle determinants, relative
+	// to the sum of the magnitudes of their terms.
+	orientBound   = (3 + 16*epsilon) * epsilon
+	incircleBound = (10 + 96*epsilon) * epsilon
+	// Precision that keeps every sum of products of coordinates and
+	// float64 weights exact.
+	exactPrec = 2048
+)
+
+// orient gives the orientation of the triangle a, b, c: 1 if it runs
+// clockwise as seen on an image (that is, with the y-axis pointing
+// down), -1 if counterclockwise, and 0 if the points are collinear.
+func orient(a, b, c Point) int {
+	acx, acy := fl(a.X)-fl(c.X), fl(a.Y)-fl(c.Y)
+	bcx, bcy := fl(b.X)-fl(c.X), fl(b.Y)-fl(c.Y)
+	l, r := acx*bcy, acy*bcx
+	if det := l - r; math.Abs(det) > orientBound*(math.Abs(l)+math.Abs(r)) {
+		return sign(det)
+	}
+
+	l2 := new(big.Int).Mul(diff(a.X, c.X), diff(b.Y, c.Y))
+	r2 := new(big.Int).Mul(diff(a.Y, c.Y), diff(b.X, c.X))
+	return l2.Cmp(r2)
+}
+
+// incircle reports where d lies relative to the circle through a, b
+// and c, which have to run clockwise as seen on an image: 1 if d is
+// inside, -1 if outside, and 0 if it is on the circle.
+func incircle(a, b, c, d Point) int {
+	adx, ady := fl(a.X)-fl(d.X), fl(a.Y)-fl(d.Y)
+	bdx, bdy := fl(b.X)-fl(d.X), fl(b.Y)-fl(d.Y)
+	cdx, cdy := fl(c.X)-fl(d.X), fl(c.Y)-fl(d.Y)
+	alift, blift, clift := adx*adx+ady*ady, bdx*bdx+bdy*bdy, cdx*cdx+cdy*cdy
+	bc, cb := bdx*cdy, cdx*bdy
+	ca, ac := cdx*ady, adx*cdy
+	ab, ba := adx*bdy, bdx*ady
+	det := alift*(bc-cb) + blift*(ca-ac) + clift*(ab-ba)
+	perm := alift*(math.Abs(bc)+math.Abs(cb)) +
+		blift*(math.Abs(ca)+math.Abs(ac)) +
+		clift*(math.Abs(ab)+math.Abs(ba))
+	if math.Abs(det) > incircleBound*perm {
+		return sign(det)
+	}
+
+	ax, ay := diff(a.X, d.X), diff(a.Y, d.Y)
+	bx, by := diff(b.X, d.X), diff(b.Y, d.Y)
+	cx, cy := diff(c.X, d.X), diff(c.Y, d.Y)
+	lift := func(x, y *big.Int) *big.Int {
+		l := new(big.Int).Mul(x, x)
+		return l.Add(l, new(big.Int).Mul(y, y))
+	}
+	cross := func(x0, y0, x1, y1 *big.Int) *big.Int {
+		l := new(big.Int).Mul(x0, y1)
+		return l.Sub(l, new(big.Int).Mul(y0, x1))
+	}
+	e := new(big.Int).Mul(lift(ax, ay), cross(bx, by, cx, cy))
+	e.Add(e, new(big.Int).Mul(lift(bx, by), cross(cx, cy, ax, ay)))
+	e.Add(e, new(big.Int).Mul(lift(cx, cy), cross(ax, ay, bx, by)))
+	return e.Sign()
+}
+
+// powerIncircle is incircle for the generators of the cells a, b, c
+// and d, with their power distances: each point is lifted by its
+// squared distance to d minus its weight relative to that of d,
+// with s the size of a pixel in FPM. It gives 0 if the four cells
+// meet in a single vertex of a Power diagram. With equal weights, it
+// is incircle.
+func powerIncircle(a, b, c, d *Cell, s float64) int {
+	if a.weight == d.weight && b.weight == d.weight && c.weight == d.weight {
+		return incircle(a.Point, b.Point, c.Point, d.Point)
+	}
+
+	// This only decides the rare case of four cells meeting in a
+	// vertex, so it is always computed exactly.
+	rat := func(x *big.Int) *big.Rat { return new(big.Rat).SetInt(x) }
+	mul := func(x, y *big.Rat) *big.Rat { return new(big.Rat).Mul(x, y) }
+	type lifted struct{ x, y, z *big.Rat }
+	lift := func(p *Cell) (l lifted) {
+		l.x, l.y = rat(diff(p.X, d.X)), rat(diff(p.Y, d.Y))
+		l.z = mul(l.x, l.x)
+		l.z.Add(l.z, mul(l.y, l.y))
+		w := new(big.Rat).SetFloat64(p.weight - d.weight)
+		return lifted{l.x, l.y, l.z.Sub(l.z, w.Mul(w, new(big.Rat).SetFloat64(s*s)))}
+	}
+	cross := func(p, q lifted) *big.Rat {
+		l := mul(p.x, q.y)
+		return l.Sub(l, mul(p.y, q.x))
+	}
+	la, lb, lc := lift(a), lift(b), lift(c)
+	e := mul(la.z, cross(lb, lc))
+	e.Add(e, mul(lb.z, cross(lc, la)))
+	e.Add(e, mul(lc.z, cross(la, lb)))
+	return e.Sign()
+}
+
+// mulDivFloor gives a*b/c rounded down, with the product taken in 128
+// bits so that it can not overflow. c must not be zero, and a must be
+// at most c, so that the result fits an uint64.
+func mulDivFloor(a, b, c uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	q, _ := bits.Div64(hi, lo, c)
+	return q
+}
+
+// mulDiv gives a*b/c rounded to the nearest integer, with the product
+// taken in 128 bits so that it can not overflow. c must not be zero,
+// and the result has to fit an int64.
+func mulDiv(a, b, c int64) int64 {
+	neg := (a < 0) != (b < 0) != (c < 0)
+	ua, ub, uc := abs64(a), abs64(b), abs64(c)
+	hi, lo := bits.Mul64(ua, ub)
+	if hi >= uc {
+		// Does not fit; leave it to math/big.
+		q := new(big.Rat).SetFrac(new(big.Int).Mul(big.NewInt(a), big.NewInt(b)), big.NewInt(c))
+		return roundRatSigned(q)
+	}
+	q, r := bits.Div64(hi, lo, uc)
+	if r >= uc-r {
+		q++
+	}
+	if neg {
+		return -int64(q)
+	}
+	return int64(q)
+}
+
+// exact gives x as a big.Float that is precise enough to keep sums of
+// products of coordinates and weights exact.
+func exact(x float64) *big.Float {
+	return new(big.Float).SetPrec(exactPrec).SetFloat64(x)
+}
+
+func fl(x uint64) float64 { return float64(x) }
+
+func diff(a, b uint64) *big.Int {
+	d := new(big.Int).SetUint64(a)
+	return d.Sub(d, new(big.Int).SetUint64(b))
+}
+
+func sign(x float64) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
+func abs64(x int64) uint64 {
+	if x < 0 {
+		return uint64(-x)
+	}
+	return uint64(x)
+}
+
+// roundRat rounds the non-negative x to the nearest integer, halves
+// rounding up.
+func roundRat(x *big.Rat) uint64 {
+	n := new(big.Int).Mul(x.Num(), big.NewInt(2))
+	n.Add(n, x.Denom())
+	return n.Quo(n, new(big.Int).Mul(x.Denom(), big.NewInt(2))).Uint64()
+}
+
+// roundRatSigned rounds x to the nearest integer, halves rounding
+// away from zero.
+func roundRatSigned(x *big.Rat) int64 {
+	if x.Sign() < 0 {
+		return -int64(roundRat(new(big.Rat).Neg(x)))
+	}
+	return int64(roundRat(x))
+}
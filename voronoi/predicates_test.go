@@ -0,0 +1,25 @@
+package voronoi
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMulDivFloor(t *testing.T) {
+	for _, test := range []struct {
+		a, b, c uint64
+		want    uint64
+	}{
+		{1, 10, 3, 3},
+		{2, 10, 3, 6},
+		{0, math.MaxUint64, 7, 0},
+		{3, math.MaxUint64, 3, math.MaxUint64},
+		// n0*mass/ncells for a mass that overflows when
+		// multiplied by n0 first.
+		{500, 1 << 60, 1001, 575884867435987500},
+	} {
+		if got := mulDivFloor(test.a, test.b, test.c); got != test.want {
+			t.Errorf("mulDivFloor(%d, %d, %d) = %d, want %d", test.a, test.b, test.c, got, test.want)
+		}
+	}
+}
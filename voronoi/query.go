@@ -0,0 +1,212 @@
+package voronoi
+
+import (
+	"math"
+	"sort"
+)
+
+// Cells returns the cells of the Diagram, sorted by the x and then
+// the y coordinate of their generator. The cells themselves are
+// shared with the Diagram, and change along with it.
+func (d *Diagram) Cells() []*Cell {
+	cs := make([]*Cell, len(d.xsorted))
+	copy(cs, d.xsorted)
+	return cs
+}
+
+// Generator returns the position of the generator of c.
+func (c *Cell) Generator() Point {
+	return c.Point
+}
+
+// Weight returns the weight of the generator of c.
+func (c *Cell) Weight() float64 {
+	return c.weight
+}
+
+// Mass returns the density integrated over the area of c, as an
+// uint64 in the FPM of the Diagram. A pixel of full density has a
+// mass of 0xFFFF shifted left by the Precision of the Diagram.
+func (c *Cell) Mass() uint64 {
+	return c.mass
+}
+
+// Centroid returns the density weighted centre of mass of c. If c has
+// no mass, this is the centre of its bounding box, and if c is empty
+// the generator.
+func (c *Cell) Centroid() Point {
+	return c.cm
+}
+
+// Polygon returns the vertices of the outline of c, clockwise as seen
+// on an image. Curved boundaries give a vertex every pixel or so. As
+// cells of a Multiplicative Diagram can have holes or fall apart, this
+// is the ring with the largest area; see Rings for all of them.
+func (c *Cell) Polygon() (p []Point) {
+	var amax float64
+	for _, r := range c.Rings() {
+		if a := area(r); p == nil || a > amax {
+			p, amax = r, a
+		}
+	}
+	return
+}
+
+// Rings returns the vertices of every closed ring of boundaries of c.
+// Outlines run clockwise and holes counterclockwise, as seen on an
+// image.
+func (c *Cell) Rings() (rs [][]Point) {
+	for _, ch := range c.chains() {
+		r := make([]Point, len(ch))
+		for i, b := range ch {
+			r[i] = b.p0
+		}
+		rs = append(rs, r)
+	}
+	return
+}
+
+// chains joins the boundaries of c into closed rings. Boundaries are
+// joined to whichever boundary starts nearest to their end, as the
+// curved boundaries of a cell do not necessarily meet exactly.
+func (c *Cell) chains() (chs [][]Boundary) {
+	bs := c.boundaries()
+	used := make([]bool, len(bs))
+	for i := range bs {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		ch := []Boundary{bs[i]}
+		for end := bs[i].p1; ; {
+			next, dmin := -1, dist2(end, bs[i].p0)
+			for j, b := range bs {
+				if d := dist2(end, b.p0); !used[j] && d < dmin {
+					next, dmin = j, d
+				}
+			}
+			if next < 0 {
+				break
+			}
+			used[next] = true
+			ch = append(ch, bs[next])
+			end = bs[next].p1
+		}
+		chs = append(chs, ch)
+	}
+	return
+}
+
+// dist2 gives the squared distance between p and q, as a float64
+// to be safe from overflow.
+func dist2(p, q Point) float64 {
+	dx := float64(p.X) - float64(q.X)
+	dy := float64(p.Y) - float64(q.Y)
+	return dx*dx + dy*dy
+}
+
+// area gives the area enclosed by the ring r, which is positive when
+// r runs clockwise as seen on an image.
+func area(r []Point) (a float64) {
+	for i, p := range r {
+		q := r[(i+1)%len(r)]
+		a += float64(p.X)*float64(q.Y) - float64(q.X)*float64(p.Y)
+	}
+	return a / 2
+}
+
+// Neighbours returns the cells that share a boundary with c, each
+// of them once.
+func (c *Cell) Neighbours() (ns []*Cell) {
+	for _, b := range c.boundaries() {
+		if b.neighbour == nil {
+			continue
+		}
+		var dup bool
+		for _, n := range ns {
+			if n == b.neighbour {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			ns = append(ns, b.neighbour)
+		}
+	}
+	return
+}
+
+// CellAt returns the cell that holds the point (x, y), in pixels, or
+// nil if the point lies outside of the image.
+//
+// The nearest generator is found by sweeping outwards from the point
+// along both the x and y-axis at once, using the xsorted and ysorted
+// orderings. A generator that is further away along either axis than
+// the nearest one found so far can still be closer (depending on the
+// weights, see within), but once both ends of either sweep are out of
+// reach, no remaining generator can be.
+func (d *Diagram) CellAt(x, y float64) (c *Cell) {
+	p0, p1 := d.maps.bounds()
+	x, y = x*d.fpm.scale(), y*d.fpm.scale()
+	if len(d.xsorted) == 0 || outside(x, y, p0, p1) > 0 || x == float64(p1.X) || y == float64(p1.Y) {
+		return nil
+	}
+
+	c, _ = d.nearest(x, y, d.wmax())
+	return c
+}
+
+// nearest returns the generator nearest to (x, y), in FPM, and its
+// distance, given wmax as the heaviest weight. See CellAt.
+func (d *Diagram) nearest(x, y, wmax float64) (c *Cell, dmin float64) {
+	sx := newScan(d.xsorted, x, func(c *Cell) uint64 { return c.X })
+	sy := newScan(d.ysorted, y, func(c *Cell) uint64 { return c.Y })
+	dmin = math.Inf(1)
+	for r := math.Inf(1); ; r = d.within(dmin, wmax) {
+		nx, okx := sx.next(r)
+		ny, oky := sy.next(r)
+		if !okx || !oky {
+			return
+		}
+		for _, n := range [2]*Cell{nx, ny} {
+			if dn := d.dist(n, x, y); dn < dmin {
+				c, dmin = n, dn
+			}
+		}
+	}
+}
+
+// A scan sweeps outwards from t over cells sorted along one axis,
+// giving the nearest unvisited one along that axis first.
+type scan struct {
+	s      []*Cell
+	lo, hi int
+	t      float64
+	coord  func(*Cell) uint64
+}
+
+func newScan(s []*Cell, t float64, coord func(*Cell) uint64) *scan {
+	hi := sort.Search(len(s), func(i int) bool { return float64(coord(s[i])) >= t })
+	return &scan{s: s, lo: hi - 1, hi: hi, t: t, coord: coord}
+}
+
+// next returns the nearest unvisited cell, as long as it is no
+// further than r away along the axis.
+func (s *scan) next(r float64) (c *Cell, ok bool) {
+	dlo, dhi := math.Inf(1), math.Inf(1)
+	if s.lo >= 0 {
+		dlo = s.t - float64(s.coord(s.s[s.lo]))
+	}
+	if s.hi < len(s.s) {
+		dhi = float64(s.coord(s.s[s.hi])) - s.t
+	}
+	switch {
+	case dlo <= dhi && dlo <= r:
+		c, ok = s.s[s.lo], true
+		s.lo--
+	case dhi < dlo && dhi <= r:
+		c, ok = s.s[s.hi], true
+		s.hi++
+	}
+	return
+}
@@ -0,0 +1,60 @@
+package voronoi
+
+import (
+	"math"
+)
+
+// Displacement holds how far the generators moved during a
+// single iteration of relaxation, in pixels.
+type Displacement struct {
+	Max, Mean float64
+}
+
+// Relax applies the given number of iterations of Lloyd relaxation
+// to the Diagram: every generator is moved to the (density weighted)
+// centre of mass of its cell, after which the cells are rebuilt.
+// This is the basis of weighted voronoi stippling as described by
+// Secord. It returns the displacement of every iteration.
+func (d *Diagram) Relax(iterations int) (dsp []Displacement) {
+	dsp = make([]Displacement, 0, iterations)
+	for i := 0; i < iterations; i++ {
+		dsp = append(dsp, d.relax())
+	}
+	return
+}
+
+// RelaxUntil is like Relax, but keeps going until no generator moves
+// more than tolerance pixels in an iteration. Because of rounding,
+// generators may keep jittering by a subpixel, so at most max
+// iterations are done. If max is zero or less, there is no limit.
+func (d *Diagram) RelaxUntil(tolerance float64, max int) (dsp []Displacement) {
+	for i := 0; max <= 0 || i < max; i++ {
+		dsp = append(dsp, d.relax())
+		if dsp[i].Max <= tolerance {
+			break
+		}
+	}
+	return
+}
+
+// relax moves every generator to the centre of mass of its
+// cell, and then rebuilds the Diagram.
+func (d *Diagram) relax() (dsp Displacement) {
+	if len(d.xsorted) == 0 {
+		return
+	}
+	var sum float64
+	for _, c := range d.xsorted {
+		dx := float64(c.cm.X) - float64(c.X)
+		dy := float64(c.cm.Y) - float64(c.Y)
+		dist := math.Sqrt(dx*dx+dy*dy) / d.fpm.scale()
+		if dist > dsp.Max {
+			dsp.Max = dist
+		}
+		sum += dist
+		c.Point = c.cm
+	}
+	dsp.Mean = sum / float64(len(d.xsorted))
+	d.buildAll()
+	return
+}
@@ -0,0 +1,496 @@
+package voronoi
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// Largest step, in pixels, taken along a curve while tracing the
+	// boundaries of a cell. Parts of a boundary that are shorter
+	// than this may be missed; it is also the largest length of
+	// the straight boundaries that a curve is split up into.
+	traceStep = 1
+	// Number of bisections used to locate the ends of boundaries.
+	traceRefine = 24
+)
+
+// A curve is a parametrised bisector, or a side of the image.
+type curve interface {
+	// at gives the point of the curve at t, in FPM.
+	at(t float64) (x, y float64)
+	// speed gives an upper bound of the distance the curve covers
+	// per unit of t, anywhere between t0 and t1.
+	speed(t0, t1 float64) float64
+}
+
+// A line runs through (x, y) in direction (dx, dy), which is a
+// unit vector, so t is the distance from (x, y).
+type line struct {
+	x, y, dx, dy float64
+}
+
+func (l line) at(t float64) (x, y float64)  { return l.x + t*l.dx, l.y + t*l.dy }
+func (l line) speed(t0, t1 float64) float64 { return 1 }
+
+// A circle around (x, y) with radius r, all in FPM, is parametrised
+// by angle. The bisectors of multiplicatively weighted cells are
+// circles.
+type circle struct {
+	x, y, r float64
+}
+
+func (c circle) at(t float64) (x, y float64) {
+	return c.x + c.r*math.Cos(t), c.y + c.r*math.Sin(t)
+}
+func (c circle) speed(t0, t1 float64) float64 { return c.r }
+
+// A hyperbola is a single branch, running through
+//
+//	(x, y) + a*cosh(t)*(ux, uy) + b*sinh(t)*(-uy, ux)
+//
+// where (ux, uy) is a unit vector pointing from the centre of the
+// hyperbola to the apex of the branch.
+type hyperbola struct {
+	x, y, ux, uy, a, b float64
+}
+
+func (h hyperbola) at(t float64) (x, y float64) {
+	p, q := h.a*math.Cosh(t), h.b*math.Sinh(t)
+	return h.x + p*h.ux - q*h.uy, h.y + p*h.uy + q*h.ux
+}
+
+// The speed at t is sqrt(a^2*sinh(t)^2 + b^2*cosh(t)^2), which grows
+// with |t| and never exceeds sqrt(a^2 + b^2)*cosh(t).
+func (h hyperbola) speed(t0, t1 float64) float64 {
+	return math.Hypot(h.a, h.b) * math.Cosh(math.Max(math.Abs(t0), math.Abs(t1)))
+}
+
+// curve gives the bisector of the generators of c and n as a curve,
+// with the range of t that takes it through the rectangle p0, p1.
+// ok is false if there is no bisector: either generator is closer
+// everywhere.
+func (d *Diagram) curve(c, n *Cell, p0, p1 Point) (cv curve, t0, t1 float64, ok bool) {
+	cx, cy := float64(c.X), float64(c.Y)
+	dx, dy := float64(n.X)-cx, float64(n.Y)-cy
+	l := math.Hypot(dx, dy)
+	if l == 0 {
+		return
+	}
+	dx, dy = dx/l, dy/l
+	mx, my := cx+dx*l/2, cy+dy*l/2
+
+	switch d.mode {
+	case Multiplicative:
+		// All points with |q - c| = k*|q - n| lie on the circle
+		// of Apollonius, unless k is one.
+		k := c.weight / n.weight
+		if k2 := k * k; math.Abs(1-k2) > 1e-9 {
+			nx, ny := float64(n.X), float64(n.Y)
+			cv = circle{(cx - k2*nx) / (1 - k2), (cy - k2*ny) / (1 - k2), k * l / math.Abs(1-k2)}
+			return cv, 0, 2 * math.Pi, true
+		}
+	case Additive:
+		// All points with |q - c| - |q - n| = wc - wn lie on
+		// the branch of a hyperbola with c and n as foci. It
+		// curves around n if c is the heavier generator.
+		delta := (c.weight - n.weight) * d.fpm.scale()
+		if math.Abs(delta) >= l {
+			return
+		}
+		if delta != 0 {
+			h := hyperbola{x: mx, y: my, ux: dx, uy: dy, a: math.Abs(delta) / 2}
+			h.b = math.Sqrt(l*l/4 - h.a*h.a)
+			if delta < 0 {
+				h.ux, h.uy = -dx, -dy
+			}
+			t1 = math.Asinh(farthest(mx, my, p0, p1) / h.b)
+			return h, -t1, t1, true
+		}
+	case Unweighted, Power:
+		return
+	}
+
+	// Equal weights: the perpendicular bisector.
+	t1 = farthest(mx, my, p0, p1)
+	return line{mx, my, -dy, dx}, -t1, t1, true
+}
+
+// farthest gives the distance from (x, y) to the farthest corner
+// of the rectangle p0, p1.
+func farthest(x, y float64, p0, p1 Point) float64 {
+	dx := math.Max(math.Abs(x-float64(p0.X)), math.Abs(x-float64(p1.X)))
+	dy := math.Max(math.Abs(y-float64(p0.Y)), math.Abs(y-float64(p1.Y)))
+	return math.Hypot(dx, dy)
+}
+
+// outside gives the distance from (x, y) to the rectangle p0, p1,
+// which is zero for points inside it.
+func outside(x, y float64, p0, p1 Point) float64 {
+	dx := math.Max(math.Max(float64(p0.X)-x, x-float64(p1.X)), 0)
+	dy := math.Max(math.Max(float64(p0.Y)-y, y-float64(p1.Y)), 0)
+	return math.Hypot(dx, dy)
+}
+
+// sample appends values of t from t0 up to t1 to ts, such that the
+// curve covers at most step, in FPM, between them. Stretches that
+// stay out of the rectangle p0, p1 are skipped, apart from their
+// start.
+func sample(cv curve, t0, t1, step float64, p0, p1 Point, ts []float64) []float64 {
+	l := cv.speed(t0, t1) * (t1 - t0)
+	if x, y := cv.at(t0); l <= step || outside(x, y, p0, p1) > l {
+		return append(ts, t0)
+	}
+	tm := (t0 + t1) / 2
+	ts = sample(cv, t0, tm, step, p0, p1, ts)
+	return sample(cv, tm, t1, step, p0, p1, ts)
+}
+
+// A vicinity holds the generators that may share a boundary with a
+// cell, as indices into xsorted in increasing order, and a rectangle
+// p0, p1 that holds all of the cell.
+type vicinity struct {
+	near   []int
+	p0, p1 Point
+}
+
+// vicinities finds the vicinity of each of the cells in cs, with wmax
+// the weight of the heaviest generator. Only the squares, see squares,
+// that overlap the rectangle r0, r1 are looked at, which has to hold
+// all of the cells. Any two of the generators that can own part of a
+// square may share a boundary there, and the rectangle of a cell holds
+// all the squares it can own part of.
+func (d *Diagram) vicinities(cs []*Cell, wmax float64, r0, r1 Point) map[*Cell]*vicinity {
+	vs := make(map[*Cell]*vicinity, len(cs))
+	for _, c := range cs {
+		vs[c] = &vicinity{}
+	}
+	near := make(map[*Cell]map[int]bool, len(cs))
+	d.squares(r0, r1, wmax, func(q0, q1 Point, marked []int) {
+		for _, i := range marked {
+			c := d.xsorted[i]
+			v, ok := vs[c]
+			if !ok {
+				continue
+			}
+			if near[c] == nil {
+				near[c] = make(map[int]bool)
+				v.p0, v.p1 = q0, q1
+			}
+			for _, j := range marked {
+				if j != i {
+					near[c][j] = true
+				}
+			}
+			v.p0 = Point{minU(v.p0.X, q0.X), minU(v.p0.Y, q0.Y)}
+			v.p1 = Point{maxU(v.p1.X, q1.X), maxU(v.p1.Y, q1.Y)}
+		}
+	})
+	for c, v := range vs {
+		for i := range near[c] {
+			v.near = append(v.near, i)
+		}
+		sort.Ints(v.near)
+	}
+	return vs
+}
+
+// squares calls f for every square of a grid over the image that
+// overlaps the rectangle r0, r1, with the corners q0, q1 of the square
+// and the generators that can own part of it, as indices into xsorted
+// in increasing order. wmax is the weight of the heaviest generator.
+// marked is only valid during the call.
+//
+// The squares have about a quarter of the average area of a cell, and
+// are laid out from the corner of the image, however much of it is
+// looked at. At the centre of every square the distance to the nearest
+// generator is found. As dist changes by no more than its slope times
+// the distance moved, a generator can only own part of the square if
+// its distance from the centre exceeds that of the nearest one by less
+// than twice the slope times the distance to a corner.
+//
+// Like owns, this only looks at the generators within reach along the
+// x-axis, so that for evenly spread generators the whole image takes
+// O(n√n), rather than the O(n²) of tracing every pair of them.
+func (d *Diagram) squares(r0, r1 Point, wmax float64, f func(q0, q1 Point, marked []int)) {
+	if len(d.xsorted) == 0 {
+		return
+	}
+	p0, p1 := d.maps.bounds()
+	x0, y0 := float64(p0.X), float64(p0.Y)
+	x1, y1 := float64(p1.X), float64(p1.Y)
+	g := math.Max(math.Sqrt((x1-x0)*(y1-y0)/float64(len(d.xsorted)))/2, d.fpm.scale())
+	wmin := d.xsorted[0].weight
+	for _, c := range d.xsorted {
+		wmin = math.Min(wmin, c.weight)
+	}
+	slack := 2 * d.slope(wmin) * g / math.Sqrt2
+
+	var marked []int
+	for y := y0 + g*(math.Floor((float64(r0.Y)-y0)/g)+0.5); y-g/2 < float64(r1.Y); y += g {
+		for x := x0 + g*(math.Floor((float64(r0.X)-x0)/g)+0.5); x-g/2 < float64(r1.X); x += g {
+			_, dmin := d.nearest(x, y, wmax)
+			r := d.within(dmin+slack, wmax)
+			lo := sort.Search(len(d.xsorted), func(i int) bool {
+				return float64(d.xsorted[i].X) >= x-r
+			})
+			marked = marked[:0]
+			for i, k := range d.xsorted[lo:] {
+				if float64(k.X) > x+r {
+					break
+				}
+				if d.dist(k, x, y) <= dmin+slack {
+					marked = append(marked, lo+i)
+				}
+			}
+			q0 := Point{uint64(math.Max(x-g/2, x0)), uint64(math.Max(y-g/2, y0))}
+			q1 := Point{uint64(math.Min(math.Ceil(x+g/2), x1)), uint64(math.Min(math.Ceil(y+g/2), y1))}
+			f(q0, q1, marked)
+		}
+	}
+}
+
+func minU(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxU(a, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// trace rebuilds the boundaries of c for the curved weighting modes.
+// The bisectors with the generators in the vicinity v of c are
+// followed through the rectangle of v, keeping the parts where no
+// third generator is closer, and so is the border of the image. wmax
+// is the weight of the heaviest generator.
+func (d *Diagram) trace(c *Cell, v *vicinity, wmax float64) {
+	p0, p1 := d.maps.bounds()
+	var chs []chain
+	for _, i := range v.near {
+		n := d.xsorted[i]
+		if n == c {
+			continue
+		}
+		// Generators identical to the one before them own nothing,
+		// and do not bound anything either.
+		if i > 0 && n.Point == d.xsorted[i-1].Point && n.weight == d.xsorted[i-1].weight {
+			continue
+		}
+		if cv, t0, t1, ok := d.curve(c, n, p0, p1); ok {
+			chs = d.follow(c, n, cv, t0, t1, v, wmax, chs)
+		}
+	}
+
+	// The border, clockwise.
+	x0, y0 := float64(p0.X), float64(p0.Y)
+	x1, y1 := float64(p1.X), float64(p1.Y)
+	chs = d.follow(c, nil, line{x0, y0, 1, 0}, 0, x1-x0, v, wmax, chs)
+	chs = d.follow(c, nil, line{x1, y0, 0, 1}, 0, y1-y0, v, wmax, chs)
+	chs = d.follow(c, nil, line{x1, y1, -1, 0}, 0, x1-x0, v, wmax, chs)
+	chs = d.follow(c, nil, line{x0, y1, 0, -1}, 0, y1-y0, v, wmax, chs)
+	c.setBoundaries(join(chs))
+}
+
+// A chain is an unbroken stretch of the boundary of a cell along one
+// curve, as straight boundaries running clockwise around the cell,
+// from p0 to p1. The generator across the chain is n, or nil for the
+// border of the image. from is what the boundary of the cell runs
+// along before the chain, and to what it runs along after it: the
+// generator that takes over where the chain ends, nil for the border,
+// or n itself where a circle closes on itself.
+type chain struct {
+	bs       []Boundary
+	p0, p1   Point
+	n        *Cell
+	from, to *Cell
+}
+
+// join puts the chains of a cell together into rings, and closes the
+// gaps between them, which rarely meet exactly after rounding. A
+// chain is followed by a chain along the curve that takes over at
+// its end, which in turn has to start where the first chain's curve
+// gives way: at a vertex, the boundary with n is followed by that
+// with k only where the boundary with k starts at n. Of such chains,
+// the one starting nearest is taken; only if there are none, as
+// where a boundary shorter than traceStep was missed, is any chain
+// that nothing leads to yet. Without closing the gaps, a row could
+// slip through one, and miss entering or leaving the cell.
+func join(chs []chain) (bs []Boundary) {
+	taken := make([]bool, len(chs))
+	for _, e := range chs {
+		next := -1
+		for pass := 0; pass < 2 && next < 0; pass++ {
+			for j, f := range chs {
+				if taken[j] || (pass == 0 && (f.n != e.to || f.from != e.n)) {
+					continue
+				}
+				if next < 0 || dist2(e.p1, f.p0) < dist2(e.p1, chs[next].p0) {
+					next = j
+				}
+			}
+		}
+		bs = append(bs, e.bs...)
+		if next < 0 {
+			continue
+		}
+		taken[next] = true
+		if p := chs[next].p0; p != e.p1 {
+			bs = append(bs, Boundary{p0: e.p1, p1: p, neighbour: e.n})
+		}
+	}
+	return bs
+}
+
+// follow appends the chains along the curve between c and n (or the
+// border, if n is nil) that bound c to chs, made up of straight
+// boundaries of at most traceStep. Only the part of the curve in the
+// rectangle of v is looked at closely.
+func (d *Diagram) follow(c, n *Cell, cv curve, t0, t1 float64, v *vicinity, wmax float64, chs []chain) []chain {
+	p0, p1 := d.maps.bounds()
+	on := func(t float64) bool {
+		x, y := cv.at(t)
+		return outside(x, y, p0, p1) == 0 && d.owns(c, n, x, y, wmax)
+	}
+	// edge finds where the curve stops bounding c between tin and
+	// tout, by bisection, and what takes over from there.
+	edge := func(tin, tout float64) (float64, *Cell) {
+		for i := 0; i < traceRefine; i++ {
+			if tm := (tin + tout) / 2; on(tm) {
+				tin = tm
+			} else {
+				tout = tm
+			}
+		}
+		x, y := cv.at(tout)
+		return tin, d.closer(c, n, x, y, wmax)
+	}
+	ts := append(sample(cv, t0, t1, traceStep*d.fpm.scale(), v.p0, v.p1, nil), t1)
+	var (
+		run  []float64
+		from *Cell
+	)
+	for i, t := range ts {
+		switch in := on(t); {
+		case in && run == nil && i > 0:
+			var te float64
+			te, from = edge(t, ts[i-1])
+			run = append(run, te, t)
+		case in && run == nil:
+			// The ends of the curve's range are corners of
+			// the image, or the seam of a circle.
+			run, from = append(run, t), n
+		case in:
+			run = append(run, t)
+		case run != nil:
+			te, to := edge(ts[i-1], t)
+			chs = d.chords(c, n, cv, append(run, te), from, to, chs)
+			run = nil
+		}
+	}
+	if run != nil {
+		chs = d.chords(c, n, cv, run, from, n, chs)
+	}
+	return chs
+}
+
+// chords appends the chain of straight boundaries between the points
+// of the curve at ts to chs, turned to run clockwise around c. from
+// and to are what takes over from the curve at the first and the last
+// of ts.
+func (d *Diagram) chords(c, n *Cell, cv curve, ts []float64, from, to *Cell, chs []chain) []chain {
+	p0, p1 := d.maps.bounds()
+	point := func(t float64) Point {
+		x, y := cv.at(t)
+		x = math.Min(math.Max(x, float64(p0.X)), float64(p1.X))
+		y = math.Min(math.Max(y, float64(p0.Y)), float64(p1.Y))
+		return Point{uint64(x + 0.5), uint64(y + 0.5)}
+	}
+	ch := chain{p0: point(ts[0]), p1: point(ts[len(ts)-1]), n: n, from: from, to: to}
+	if n != nil {
+		// The outward normal has to point to where the generator
+		// of n gets closer than that of c. Along an unbroken
+		// stretch of the curve, that is the same side throughout.
+		tm := (ts[0] + ts[len(ts)-1]) / 2
+		x, y := cv.at(tm)
+		dx, dy := cv.at(tm + 1e-6*(ts[len(ts)-1]-ts[0]))
+		dx, dy = dx-x, dy-y
+		cx, cy := d.mode.grad(c, x, y)
+		nx, ny := d.mode.grad(n, x, y)
+		if dy*(cx-nx)-dx*(cy-ny) < 0 {
+			for i, j := 0, len(ts)-1; i < j; i, j = i+1, j-1 {
+				ts[i], ts[j] = ts[j], ts[i]
+			}
+			ch.p0, ch.p1, ch.from, ch.to = ch.p1, ch.p0, to, from
+		}
+	}
+	for i := 1; i < len(ts); i++ {
+		b := Boundary{p0: point(ts[i-1]), p1: point(ts[i]), neighbour: n}
+		if b.p0 != b.p1 {
+			ch.bs = append(ch.bs, b)
+		}
+	}
+	return append(chs, ch)
+}
+
+// closer returns the generator other than those of c and n that is
+// closest to (x, y), if it is closer than that of c, or nil if there
+// is none or the point lies outside of the image.
+func (d *Diagram) closer(c, n *Cell, x, y, wmax float64) (k *Cell) {
+	p0, p1 := d.maps.bounds()
+	if outside(x, y, p0, p1) > 0 {
+		return nil
+	}
+	dmin := d.dist(c, x, y)
+	r := d.within(dmin, wmax)
+	lo := sort.Search(len(d.xsorted), func(i int) bool {
+		return float64(d.xsorted[i].X) >= x-r
+	})
+	for _, m := range d.xsorted[lo:] {
+		if float64(m.X) > x+r {
+			break
+		}
+		if m == c || m == n {
+			continue
+		}
+		if dm := d.dist(m, x, y); dm < dmin {
+			k, dmin = m, dm
+		}
+	}
+	return k
+}
+
+// owns reports whether no generator other than those of c and n is
+// closer to (x, y) than that of c. Since the cells are sorted along
+// the x-axis, only those generators within reach along it need to
+// be checked. Of generators at the same position and with the same
+// weight, only the first in xsorted owns anything.
+func (d *Diagram) owns(c, n *Cell, x, y, wmax float64) bool {
+	dc := d.dist(c, x, y)
+	r := d.within(dc, wmax)
+	lo := sort.Search(len(d.xsorted), func(i int) bool {
+		return float64(d.xsorted[i].X) >= x-r
+	})
+	var after bool
+	for _, k := range d.xsorted[lo:] {
+		if float64(k.X) > x+r {
+			break
+		}
+		if k == c {
+			after = true
+			continue
+		}
+		if k == n {
+			continue
+		}
+		if dk := d.dist(k, x, y); dk < dc || (!after && k.Point == c.Point && k.weight == c.weight) {
+			return false
+		}
+	}
+	return true
+}
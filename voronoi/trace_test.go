@@ -0,0 +1,56 @@
+package voronoi
+
+import (
+	"github.com/kortschak/go-stippling/density"
+	"testing"
+)
+
+func TestVicinities(t *testing.T) {
+	for _, mode := range []WeightMode{Additive, Multiplicative} {
+		d := NewDiagram(testImage(300, 200), density.AvgDensity, 100)
+		d.SetWeights(mode, func(p Point) float64 {
+			v := float64((p.X*7+p.Y*13)>>d.Precision()%5) + 1
+			if mode == Additive {
+				return v * v * 2
+			}
+			return 0.2 + v*v/8
+		})
+		p0, p1 := d.maps.bounds()
+		vs := d.vicinities(d.xsorted, d.wmax(), p0, p1)
+		for _, c := range d.xsorted {
+			v := vs[c]
+			near := make(map[*Cell]bool)
+			for _, i := range v.near {
+				near[d.xsorted[i]] = true
+			}
+			for _, b := range c.boundaries() {
+				if n := b.neighbour; n != nil && !near[n] {
+					t.Errorf("mode %d: neighbour %v of cell at %v not in its vicinity", mode, n.Point, c.Point)
+				}
+				for _, p := range [2]Point{b.p0, b.p1} {
+					if p.X < v.p0.X || p.Y < v.p0.Y || p.X > v.p1.X || p.Y > v.p1.Y {
+						t.Errorf("mode %d: vertex %v of cell at %v outside of %v-%v", mode, p, c.Point, v.p0, v.p1)
+					}
+				}
+			}
+		}
+		checkClosed(t, d)
+	}
+}
+
+// checkClosed reports every cell in d whose boundaries do not form
+// closed rings.
+func checkClosed(t *testing.T, d *Diagram) {
+	for _, c := range d.xsorted {
+		ends := make(map[Point]int)
+		for _, b := range c.boundaries() {
+			ends[b.p0]++
+			ends[b.p1]--
+		}
+		for p, n := range ends {
+			if n != 0 {
+				t.Errorf("cell at %v has a loose end at %v", c.Point, p)
+			}
+		}
+	}
+}
@@ -1,183 +1,302 @@
+/*
+Package voronoi implements weighted voronoi diagrams, with the
+weights given by the density maps of the density package.
+
+Generators are placed on the image through a mass bisecting guess,
+or one of the other Placement strategies, after which every cell
+gets its boundaries and the mass it covers, integrated over the
+density maps with subpixel precision.
+*/
 package voronoi
 
 import (
 	"github.com/kortschak/go-stippling/density"
 	"image"
+	"math"
+	"sort"
 )
 
-// Almost identical to image.Point, but using 64 bit integer FPM,
-// with the fractional part being 10 bits - 1024 by 1024 subpixel
-// precision should be enough for most intents and purposes.
+// Almost identical to image.Point, but using 64 bit integer FPM.
+// The number of bits of the fractional part is the precision of the
+// Diagram the Point belongs to, see Diagram.Precision: a pixel holds
+// 2^prec by 2^prec subpixels. DefaultPrecision should be enough for
+// most intents and purposes, and MaxPrecision gives the most that
+// an image allows, see NewDiagramPrecision.
 //
-// Note that summing over an area gives a total of (2^10)*(2^10)
-// subpixels, in other words: the mass' fraction can have up to
-// 20 bit "precision" if necessary.
+// Note that summing over an area gives a total of 2^prec*2^prec
+// subpixels per pixel, in other words: the mass' fraction can have
+// twice the precision of the Point if necessary.
 type Point struct {
 	X, Y uint64
 }
 
 // A Boundary is saved as a starting and ending point,
-// and a pointer to the neighbouring Voronoi cell.
+// and a pointer to the neighbouring Voronoi cell. The
+// neighbour is nil when the boundary lies on the edge
+// of the image. Curved boundaries are split up into
+// short, straight ones, see traceStep.
 type Boundary struct {
 	p0, p1    Point
-	neighbour *cell
+	neighbour *Cell
 }
 
-func (b Boundary) toXLine(l lineEq) {
-	if b.p0.X > b.p1.X {
-		b.p0, b.p1 = b.p1, b.p0
-	}
+// xAt gives the x where b crosses the horizontal line through y,
+// rounded to the nearest FPM. The product in there can take up to
+// 70 bits, so it is done by mulDiv. b must not be horizontal.
+func (b Boundary) xAt(y uint64) uint64 {
+	dy := int64(b.p1.Y) - int64(b.p0.Y)
+	dx := int64(b.p1.X) - int64(b.p0.X)
+	return uint64(int64(b.p0.X) + mulDiv(int64(y)-int64(b.p0.Y), dx, dy))
+}
 
-	l.a0 = b.p1.Y - b.p0.Y
-	l.a1 = b.p1.X - b.p0.X
-	if l.a1 != 0 {
-		l.b = b.p0.Y - b.p0.X*l.a0/l.a1
-	}
+// yAt gives the y where b crosses the vertical line through x, like
+// xAt. b must not be vertical.
+func (b Boundary) yAt(x uint64) uint64 {
+	dy := int64(b.p1.Y) - int64(b.p0.Y)
+	dx := int64(b.p1.X) - int64(b.p0.X)
+	return uint64(int64(b.p0.Y) + mulDiv(int64(x)-int64(b.p0.X), dy, dx))
 }
 
-func (b Boundary) toYLine(l lineEq) {
-	if b.p0.Y > b.p1.Y {
-		b.p0, b.p1 = b.p1, b.p0
-	}
+// crossesY reports whether the horizontal line through y crosses b.
+// The lowest end of b counts, the highest does not, so that a line
+// through a vertex crosses only one of the boundaries meeting there,
+// unless the vertex is the top or bottom of the cell.
+func (b Boundary) crossesY(y uint64) bool {
+	return (b.p0.Y <= y && y < b.p1.Y) || (b.p1.Y <= y && y < b.p0.Y)
+}
 
-	l.a0 = b.p1.X - b.p0.X
-	l.a1 = b.p1.Y - b.p0.Y
-	if l.a1 != 0 {
-		l.b = b.p0.X - b.p0.Y*l.a0/l.a1
-	}
+// crossesX reports whether the vertical line through x crosses b,
+// with the same convention as crossesY.
+func (b Boundary) crossesX(x uint64) bool {
+	return (b.p0.X <= x && x < b.p1.X) || (b.p1.X <= x && x < b.p0.X)
 }
 
-type cell struct {
+// A Cell is the part of the image that is closer to its generator
+// than to any other, in the sense of the WeightMode of the Diagram.
+type Cell struct {
 	Point
+	// Boundaries are sorted by the side of the cell they are on, that
+	// is: the direction of their outward normal. A slanted boundary
+	// is therefore both in up or down, and in left or right.
 	up, down, left, right []Boundary
 	mass, nmass           uint64
+	// Centre of mass of the cell
+	cm Point
+	// Weight of the generator, see WeightMode for its meaning.
+	// Zero for a regular voronoi diagram.
+	weight float64
 }
 
-type Diagram struct {
-	xsorted, ysorted []*cell
-	maps
+// setBoundaries replaces the boundaries of c by bs. Every boundary
+// has to run clockwise around the cell (as seen on an image, that
+// is with the y-axis pointing down), so that its outward normal
+// is (dy, -dx).
+func (c *Cell) setBoundaries(bs []Boundary) {
+	c.up, c.down, c.left, c.right = c.up[:0], c.down[:0], c.left[:0], c.right[:0]
+	for _, b := range bs {
+		dx := int64(b.p1.X) - int64(b.p0.X)
+		dy := int64(b.p1.Y) - int64(b.p0.Y)
+		if dy < 0 {
+			c.left = append(c.left, b)
+		} else if dy > 0 {
+			c.right = append(c.right, b)
+		}
+		if dx > 0 {
+			c.up = append(c.up, b)
+		} else if dx < 0 {
+			c.down = append(c.down, b)
+		}
+	}
 }
 
-func NewDiagram(i image.Image, m density.Model, ncells uint64) (d *Diagram) {
-	d.maps.dmap = density.MapFrom(i, m)
-	d.maps.sumx = density.SumXFrom(i, m)
-	d.maps.sumy = density.SumYFrom(i, m)
-	d.maps.dsum = density.DSumFrom(i, m)
-
-	d.xsorted = make([]*cell, ncells)
-	d.ysorted = make([]*cell, ncells)
-
-	// Initial guess as to where the generators should be put.
-	cellchan := make(chan Point)
-	rect := d.maps.dmap.Bounds()
-	// Theoretically, rect.Min can be negative, but since we open
-	// the images ourselves we know that will never happen, and
-	// in fact it is guaranteed that the values will be zero.
-	p0 := Point{uint64(rect.Min.X << 12), uint64(rect.Min.Y << 12)}
-	p1 := Point{uint64(rect.Max.X << 12), uint64(rect.Max.Y << 12)}
-	go guess(d.maps, p0, p1, ncells, cellchan)
-	nillbound := make([]Boundary, 0, 0)
-	for i := 0; uint64(i) < ncells; i++ {
-		p := &cell{
-			Point: <-cellchan,
-			up:    nillbound,
-			down:  nillbound,
-			left:  nillbound,
-			right: nillbound,
-			mass:  0,
-			nmass: 0,
+// boundaries returns every boundary of c once.
+func (c *Cell) boundaries() (bs []Boundary) {
+	bs = make([]Boundary, 0, len(c.left)+len(c.right)+2)
+	bs = append(bs, c.left...)
+	bs = append(bs, c.right...)
+	for _, ud := range [][]Boundary{c.up, c.down} {
+		for _, b := range ud {
+			if b.p0.Y == b.p1.Y {
+				bs = append(bs, b)
+			}
 		}
+	}
+	return
+}
 
-		// Not the most efficient way to sort, but it works
-		for j := 0; j < i; j++ {
-			if p.X > d.xsorted[j].X {
-				for k := i; k > j; k-- {
-					d.xsorted[k] = d.xsorted[k-1]
+// extent returns the bounding box of the boundaries of c.
+func (c *Cell) extent() (p0, p1 Point) {
+	p0 = Point{math.MaxUint64, math.MaxUint64}
+	for _, bs := range [][]Boundary{c.up, c.down, c.left, c.right} {
+		for _, b := range bs {
+			for _, p := range [2]Point{b.p0, b.p1} {
+				if p.X < p0.X {
+					p0.X = p.X
 				}
-				d.xsorted[j] = p
-				j = i
-			}
-		}
-		for j := 0; j < i; j++ {
-			if p.Y > d.ysorted[j].Y {
-				for k := i; k > j; k-- {
-					d.ysorted[k] = d.ysorted[k-1]
+				if p.Y < p0.Y {
+					p0.Y = p.Y
+				}
+				if p.X > p1.X {
+					p1.X = p.X
+				}
+				if p.Y > p1.Y {
+					p1.Y = p.Y
 				}
-				d.ysorted[j] = p
-				j = i
 			}
 		}
 	}
+	if p0.X > p1.X {
+		p0 = p1
+	}
+	return
+}
 
-	//TODO: implement sweepline algorithm that gives cells their
-	//		initial boundaries and mass values.
+// vertices returns the sorted x and y coordinates of the
+// vertices of c.
+func (c *Cell) vertices() (xs, ys []uint64) {
+	for _, b := range c.boundaries() {
+		xs = append(xs, b.p0.X, b.p1.X)
+		ys = append(ys, b.p0.Y, b.p1.Y)
+	}
+	sort.Sort(uint64s(xs))
+	sort.Sort(uint64s(ys))
 	return
 }
 
-// The guessing algorithm is based on the simple observation that once
-// in equilibrium, all generators have mass equal to:
-//
-//   total mass / total generators
-//
-// Hence, the following should result in a decent initial guess: Take
-// the density map, split it in two along the longest axis such that
-// the cells can be divided as evenly as possible among the submaps,
-// and the mass is divided proportionaly to the number of cells being
-// divided. Repeat this process with the submaps, until there is only
-// one cell left in a submap, which will have average mass. To get an
-// early start on Floyd relaxation, the center of the generator is
-// then put on the centre of mass of this submap.
-//
-// Note that we can trivially make this algorithm concurrent.
-func guess(m maps, p0, p1 Point, ncells uint64, c chan Point) {
-	if ncells == 1 {
-		c <- m.cm(p0, p1)
-	} else {
-		n0 := ncells >> 1
-		n1 := ncells - n0
-		mass := m.subMass(p0, p1)
-		targetmass := n0 * mass / ncells
-
-		dx := p1.X - p0.X
-		dy := p1.Y - p0.Y
-		dp := p1
-
-		// divide along longest axis
-		if dx < dy {
-			y := dy >> 1
-			dp.Y = p0.Y + y
-			dmass := m.subMass(p0, dp)
-			for dmass != targetmass {
-				dy >>= 1
-				if dmass < targetmass {
-					y += dy
-				} else {
-					y -= dy
-				}
-				dp.Y = p0.Y + y
-				dmass := m.subMass(p0, dp)
+// rowSpan appends the parts of the horizontal line through y that
+// lie within c to s. Crossing a left boundary enters the cell and
+// crossing a right one leaves it, which also works for cells that
+// are not convex, or have holes.
+func (c *Cell) rowSpan(y uint64, s []uint64) []uint64 {
+	var xs crossings
+	for _, b := range c.left {
+		if b.crossesY(y) {
+			xs = append(xs, crossing{b.xAt(y), 1})
+		}
+	}
+	for _, b := range c.right {
+		if b.crossesY(y) {
+			xs = append(xs, crossing{b.xAt(y), -1})
+		}
+	}
+	return xs.spans(s)
+}
+
+// colSpan is the column equivalent of rowSpan, entering the cell
+// through the up boundaries and leaving it through the down ones.
+func (c *Cell) colSpan(x uint64, s []uint64) []uint64 {
+	var ys crossings
+	for _, b := range c.up {
+		if b.crossesX(x) {
+			ys = append(ys, crossing{b.yAt(x), 1})
+		}
+	}
+	for _, b := range c.down {
+		if b.crossesX(x) {
+			ys = append(ys, crossing{b.yAt(x), -1})
+		}
+	}
+	return ys.spans(s)
+}
+
+// A crossing is where a line crosses a boundary, at t along the
+// line. dir is 1 when entering the cell there, -1 when leaving.
+type crossing struct {
+	t   uint64
+	dir int
+}
+
+type crossings []crossing
+
+func (s crossings) Len() int      { return len(s) }
+func (s crossings) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s crossings) Less(i, j int) bool {
+	return s[i].t < s[j].t || (s[i].t == s[j].t && s[i].dir > s[j].dir)
+}
+
+// spans appends the stretches between the crossings where the line
+// is inside the cell to s, as pairs of begin and end. Leaving the
+// cell before having entered it, which rounding can cause at the
+// very ends of the cell, is ignored.
+func (s crossings) spans(t []uint64) []uint64 {
+	sort.Sort(s)
+	var w int
+	var t0 uint64
+	for _, x := range s {
+		switch {
+		case x.dir > 0:
+			if w == 0 {
+				t0 = x.t
 			}
-			go guess(m, p0, dp, n0, c)
-			dp.X = p0.X
-			go guess(m, dp, p1, n1, c)
-		} else {
-			x := dx >> 1
-			dp.X = p0.X + x
-			dmass := m.subMass(p0, dp)
-			for dmass != targetmass {
-				dx >>= 1
-				if dmass < targetmass {
-					x += dx
-				} else {
-					x -= dx
-				}
-				dp.X = p1.X - x
-				dmass := m.subMass(p0, dp)
+			w++
+		case w > 0:
+			w--
+			if w == 0 && t0 < x.t {
+				t = append(t, t0, x.t)
 			}
-			go guess(m, p0, dp, n0, c)
-			dp.Y = p0.Y
-			go guess(m, dp, p1, n1, c)
 		}
 	}
+	return t
+}
+
+type uint64s []uint64
+
+func (s uint64s) Len() int           { return len(s) }
+func (s uint64s) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint64s) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type byX []*Cell
+
+func (s byX) Len() int           { return len(s) }
+func (s byX) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byX) Less(i, j int) bool { return lessX(s[i], s[j]) }
+
+type byY []*Cell
+
+func (s byY) Len() int           { return len(s) }
+func (s byY) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byY) Less(i, j int) bool { return lessY(s[i], s[j]) }
+
+// lessX orders cells by the x and then the y of their generator,
+// lessY the other way around.
+func lessX(a, b *Cell) bool { return a.X < b.X || (a.X == b.X && a.Y < b.Y) }
+func lessY(a, b *Cell) bool { return a.Y < b.Y || (a.Y == b.Y && a.X < b.X) }
+
+type Diagram struct {
+	xsorted, ysorted []*Cell
+	mode             WeightMode
+	maps
+}
+
+// Precision returns the number of fractional bits of the FPM of the
+// Diagram.
+func (d *Diagram) Precision() uint {
+	return uint(d.fpm)
+}
+
+// NewDiagram converts image i to density maps according to model m,
+// places ncells generators on it by Bisection and returns the
+// resulting Diagram, with the boundaries and mass of every cell
+// filled in. The Diagram uses the DefaultPrecision. See Place for
+// other ways to put the generators on the image.
+func NewDiagram(i image.Image, m density.Model, ncells uint64) (d *Diagram) {
+	return NewDiagramPrecision(i, m, ncells, DefaultPrecision)
+}
+
+// NewDiagramPrecision is like NewDiagram, but the FPM of the Diagram
+// has prec fractional bits. It panics if prec exceeds the
+// MaxPrecision of the bounds of i.
+func NewDiagramPrecision(i image.Image, m density.Model, ncells uint64, prec uint) (d *Diagram) {
+	if prec > MaxPrecision(i.Bounds()) {
+		panic("voronoi: precision out of range")
+	}
+	d = new(Diagram)
+	d.maps.fpm = fpm(prec)
+	d.maps.dmap = density.MapFrom(i, m)
+	d.maps.sumx = density.SumXFrom(i, m)
+	d.maps.sumy = density.SumYFrom(i, m)
+	d.maps.sums = density.MomentSumFrom(i, m)
+
+	d.Place(Bisection, ncells, 0)
 	return
 }
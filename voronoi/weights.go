@@ -0,0 +1,143 @@
+package voronoi
+
+import (
+	"math"
+)
+
+// WeightMode determines how the weight of a generator affects the
+// distance from a point to it, and with that the shape of the cells.
+type WeightMode int
+
+const (
+	// Unweighted gives a regular voronoi diagram, ignoring weights.
+	Unweighted WeightMode = iota
+	// Additive subtracts the weight, in pixels, from the distance.
+	// The bisectors are branches of hyperbolas, and cells may be
+	// empty when a generator is too close to a heavier one.
+	Additive
+	// Multiplicative divides the distance by the weight, which has
+	// to be positive. The bisectors are circles around the lighter
+	// generator, so cells need not be convex, can have holes, and
+	// can even fall apart.
+	Multiplicative
+	// Power subtracts the weight, in squared pixels, from the
+	// squared distance. The bisectors are straight, so cells stay
+	// convex. This is the mode used by Balance.
+	Power
+)
+
+// Mode returns the weighting mode of the Diagram.
+func (d *Diagram) Mode() WeightMode {
+	return d.mode
+}
+
+// SetWeights switches the Diagram to the given weighting mode, gives
+// every generator weight(p), with p its position, and rebuilds the
+// cells. The weights stay with the generators when they move. If
+// weight is nil, all generators get a weight of one, or zero for the
+// Additive and Power modes; Multiplicative weights that are not
+// positive are taken to be one as well.
+//
+// The straight bisectors of the Unweighted and Power modes allow
+// cells to be cut out of the image directly. The curved bisectors of
+// the other modes have to be traced, along every pixel of the
+// boundaries, which takes a lot longer for the same number of
+// generators; see vicinities for how the bisectors worth tracing are
+// found.
+func (d *Diagram) SetWeights(mode WeightMode, weight func(p Point) float64) {
+	d.mode = mode
+	for _, c := range d.xsorted {
+		c.weight = 0
+		if weight != nil && mode != Unweighted {
+			c.weight = weight(c.Point)
+		}
+		if mode == Multiplicative && !(c.weight > 0) {
+			c.weight = 1
+		}
+	}
+	d.buildAll()
+}
+
+// defaultWeight gives the weight of new generators: one for the
+// Multiplicative mode, and zero for the others.
+func (m WeightMode) defaultWeight() float64 {
+	if m == Multiplicative {
+		return 1
+	}
+	return 0
+}
+
+// curved reports whether the bisectors of m are curved, so that cells
+// have to be traced rather than clipped.
+func (m WeightMode) curved() bool {
+	return m == Additive || m == Multiplicative
+}
+
+// wmax gives the weight of the heaviest generator.
+func (d *Diagram) wmax() (w float64) {
+	for k, c := range d.xsorted {
+		if k == 0 || c.weight > w {
+			w = c.weight
+		}
+	}
+	return
+}
+
+// dist gives the weighted distance from (x, y) to the generator
+// of c, all in FPM. For Power and Unweighted that is the squared
+// distance.
+func (d *Diagram) dist(c *Cell, x, y float64) float64 {
+	dx, dy := x-float64(c.X), y-float64(c.Y)
+	s := d.fpm.scale()
+	switch d.mode {
+	case Additive:
+		return math.Sqrt(dx*dx+dy*dy) - c.weight*s
+	case Multiplicative:
+		return math.Sqrt(dx*dx+dy*dy) / c.weight
+	case Power:
+		return dx*dx + dy*dy - c.weight*s*s
+	}
+	return dx*dx + dy*dy
+}
+
+// grad gives the gradient of dist at (x, y).
+func (m WeightMode) grad(c *Cell, x, y float64) (gx, gy float64) {
+	dx, dy := x-float64(c.X), y-float64(c.Y)
+	switch m {
+	case Additive, Multiplicative:
+		l := math.Sqrt(dx*dx + dy*dy)
+		if l == 0 {
+			return
+		}
+		if m == Multiplicative {
+			l *= c.weight
+		}
+		return dx / l, dy / l
+	}
+	return 2 * dx, 2 * dy
+}
+
+// slope gives the most that dist can change per FPM moved, for the
+// curved modes, given wmin as the lightest weight.
+func (d *Diagram) slope(wmin float64) float64 {
+	if d.mode == Multiplicative {
+		return 1 / wmin
+	}
+	return 1
+}
+
+// within gives how far from a point another generator can be while
+// still being closer than dist, the distance to the current nearest
+// one, given wmax as the heaviest weight.
+func (d *Diagram) within(dist, wmax float64) float64 {
+	s := d.fpm.scale()
+	switch d.mode {
+	case Additive:
+		return math.Max(dist+wmax*s, 0)
+	case Multiplicative:
+		return dist * wmax
+	case Power:
+		return math.Sqrt(math.Max(dist+wmax*s*s, 0))
+	}
+	return math.Sqrt(dist)
+}